@@ -473,20 +473,7 @@ func (NullUnixTime) GormDataType() string {
 
 // GormDBDataType returns gorm DB data type based on the current using database.
 func (NullUnixTime) GormDBDataType(db *gorm.DB, field *schema.Field) string {
-	switch db.Dialector.Name() {
-	case "sqlite":
-		return "INTEGER"
-	case "mysql":
-		return "BIGINT"
-	case "postgres":
-		return "bigint"
-	case "sqlserver":
-		return "INTEGER"
-	case "firebird":
-		return "INTEGER"
-	default:
-		return "INTEGER"
-	}
+	return GetDialect(db.Dialector.Name()).ColumnType("NullUnixTime")
 }
 
 // Scan implements sql.Scanner interface and scans value into Date
@@ -560,20 +547,7 @@ func (NullDate) GormDataType() string {
 
 // GormDBDataType returns gorm DB data type based on the current using database.
 func (NullDate) GormDBDataType(db *gorm.DB, field *schema.Field) string {
-	switch db.Dialector.Name() {
-	case "sqlite":
-		return "TEXT"
-	case "mysql":
-		return "DATE"
-	case "postgres":
-		return "DATE"
-	case "sqlserver":
-		return "DATE"
-	case "firebird":
-		return "DATE"
-	default:
-		return "DATE"
-	}
+	return GetDialect(db.Dialector.Name()).ColumnType("NullDate")
 }
 
 // Scan implements sql.Scanner interface and scans value into Date
@@ -652,20 +626,7 @@ func (NullTime) GormDataType() string {
 
 // GormDBDataType returns gorm DB data type based on the current using database.
 func (NullTime) GormDBDataType(db *gorm.DB, field *schema.Field) string {
-	switch db.Dialector.Name() {
-	case "sqlite":
-		return "TEXT"
-	case "mysql":
-		return "TIME"
-	case "postgres":
-		return "TIME"
-	case "sqlserver":
-		return "TIME"
-	case "firebird":
-		return "TIME"
-	default:
-		return "TIME"
-	}
+	return GetDialect(db.Dialector.Name()).ColumnType("NullTime")
 }
 
 // Scan implements sql.Scanner interface and scans value into Time
@@ -712,20 +673,7 @@ func (NullText) GormDataType() string {
 
 // GormDBDataType returns gorm DB data type based on the current using database.
 func (NullText) GormDBDataType(db *gorm.DB, field *schema.Field) string {
-	switch db.Dialector.Name() {
-	case "sqlite":
-		return "TEXT"
-	case "mysql":
-		return "TEXT"
-	case "postgres":
-		return "TEXT"
-	case "sqlserver":
-		return "NVARCHAR(MAX)"
-	case "firebird":
-		return "BLOB SUB_TYPE TEXT"
-	default:
-		return "TEXT"
-	}
+	return GetDialect(db.Dialector.Name()).ColumnType("NullText")
 }
 
 // NullJSON represents a nullable JSON value.
@@ -802,20 +750,7 @@ func (NullJSON) GormDataType() string {
 
 // GormDBDataType returns gorm DB data type based on the current using database.
 func (NullJSON) GormDBDataType(db *gorm.DB, field *schema.Field) string {
-	switch db.Dialector.Name() {
-	case "sqlite":
-		return "JSON"
-	case "mysql":
-		return "JSON"
-	case "postgres":
-		return "JSONB"
-	case "sqlserver":
-		return "NVARCHAR(MAX)"
-	case "firebird":
-		return "BLOB SUB_TYPE TEXT"
-	default:
-		return "TEXT"
-	}
+	return GetDialect(db.Dialector.Name()).ColumnType("NullJSON")
 }
 
 // isNullJSON checks if a reflect type is a NullJSON type.
@@ -869,12 +804,7 @@ func (NullUUID) GormDataType() string {
 
 // GormDBDataType returns gorm DB data type based on the current using database.
 func (NullUUID) GormDBDataType(db *gorm.DB, field *schema.Field) string {
-	switch db.Dialector.Name() {
-	case "postgres":
-		return "uuid"
-	default:
-		return "char(36)"
-	}
+	return GetDialect(db.Dialector.Name()).ColumnType("NullUUID")
 }
 
 // MapSlice of map items.
@@ -903,6 +833,7 @@ func (ms MapSlice) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// StandardDataType is the standard data type with the alternatives keyed by dialect name, see StandardDataTypes
 type StandardDataType struct {
 	Default      string
 	Alternatives map[string]string
@@ -16,6 +16,7 @@ type DB struct {
 	Conns      map[string]*gorm.DB
 	Migrations map[string]map[string]Table
 	Seeders    map[string]map[string]any
+	Replicas   map[string]*Replicas
 	mu         sync.RWMutex
 }
 
@@ -95,6 +96,10 @@ func (db *DB) CloseConn(connName string) error {
 			dbSQL.Close()
 		}
 		delete(db.Conns, connName)
+		if r := db.Replicas[connName]; r != nil {
+			r.close()
+			delete(db.Replicas, connName)
+		}
 		return err
 	}
 	return NewError(http.StatusInternalServerError, "DB connection "+connName+" is not found")
@@ -111,6 +116,10 @@ func (db *DB) Close() {
 		}
 	}
 	db.Conns = map[string]*gorm.DB{}
+	for _, r := range db.Replicas {
+		r.close()
+	}
+	db.Replicas = map[string]*Replicas{}
 }
 
 // RegisterTable registers a table for migration.
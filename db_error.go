@@ -0,0 +1,272 @@
+package grest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// DB error messages, the driver message is not used because it may contains SQL or the data
+var (
+	DBErrUniqueViolation       = "The data already exists."
+	DBErrForeignKeyReferenced  = "The data is still referenced by another data."
+	DBErrForeignKeyNotFound    = "The referenced data is not found."
+	DBErrNotNullViolation      = "The required data is empty."
+	DBErrCheckViolation        = "The data is invalid."
+	DBErrSerializationFailure  = "The data is being updated by another process, please try again."
+	DBErrStatementTimeout      = "The process takes too long, please try again later."
+	DBErrInternal              = "Something went wrong while processing the data, please try again later."
+	dbErrQuotedNameRegexp      = regexp.MustCompile("['\"`\\[]([^'\"`\\]]+)['\"`\\]]")
+	dbErrPostgresKeyRegexp     = regexp.MustCompile(`Key \(([^)]+)\)`)
+	dbErrMySQLForeignKeyRegexp = regexp.MustCompile("CONSTRAINT `([^`]+)` FOREIGN KEY \\(`([^`]+)`\\)")
+	dbErrSQLServerColumnRegexp = regexp.MustCompile(`column '([^']+)'`)
+)
+
+// dbErrorInfo is the recognized DB error
+type dbErrorInfo struct {
+	code       int
+	message    string
+	constraint string
+	column     string
+	retryable  bool
+}
+
+// TranslateDBError translates the DB error (postgres, mysql, sqlite & sqlserver) to *Error with meaningful status code :
+//
+//   - unique violation : 409
+//   - foreign key violation : 409 if the data is still referenced, 422 if the referenced data is not found
+//   - not null & check violation : 422
+//   - serialization failure & deadlock : 503 (retryable)
+//   - statement timeout : 504
+//
+// the detail contains the constraint and the column (if any), and retryable for 503,
+// the err is returned as is if it is *Error or not recognized
+func TranslateDBError(err error) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{}
+	if errors.As(err, &e) {
+		return e
+	}
+	info, ok := dbErrorToInfo(err)
+	if !ok {
+		return err
+	}
+	detail := map[string]any{}
+	if info.constraint != "" {
+		detail["constraint"] = info.constraint
+	}
+	if info.column != "" {
+		detail["column"] = info.column
+	}
+	if info.retryable {
+		detail["retryable"] = true
+	}
+	return NewError(info.code, info.message, detail)
+}
+
+// dbErrorToInfo recognizes the DB error by the SQLSTATE (postgres), the error number (mysql & sqlserver) or the message (sqlite)
+func dbErrorToInfo(err error) (dbErrorInfo, bool) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return dbErrorInfo{code: http.StatusGatewayTimeout, message: DBErrStatementTimeout}, true
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		rv := reflect.ValueOf(e)
+		if rv.Kind() == reflect.Pointer {
+			rv = rv.Elem()
+		}
+		if rv.Kind() != reflect.Struct {
+			continue
+		}
+		msg := dbErrorStringField(rv, "Message")
+		if code := dbErrorStringField(rv, "Code"); len(code) == 5 {
+			info, ok := postgresErrorToInfo(code, msg+" "+dbErrorStringField(rv, "Detail"))
+			if ok {
+				info.constraint = firstNonEmpty(dbErrorStringField(rv, "ConstraintName"), dbErrorStringField(rv, "Constraint"))
+				info.column = firstNonEmpty(dbErrorStringField(rv, "ColumnName"), dbErrorStringField(rv, "Column"), info.column)
+				return info, true
+			}
+		}
+		if number := rv.FieldByName("Number"); number.IsValid() && number.CanInt() {
+			if info, ok := sqlServerErrorToInfo(number.Int(), msg); ok {
+				return info, true
+			}
+		} else if number.IsValid() && number.CanUint() {
+			if info, ok := mysqlErrorToInfo(number.Uint(), msg); ok {
+				return info, true
+			}
+		}
+	}
+	if info, ok := sqliteErrorToInfo(err.Error()); ok {
+		return info, true
+	}
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return dbErrorInfo{code: http.StatusConflict, message: DBErrUniqueViolation}, true
+	}
+	if errors.Is(err, gorm.ErrForeignKeyViolated) {
+		return dbErrorInfo{code: http.StatusConflict, message: DBErrForeignKeyReferenced}, true
+	}
+	return dbErrorInfo{}, false
+}
+
+// postgresErrorToInfo recognizes postgres error by SQLSTATE, see https://www.postgresql.org/docs/current/errcodes-appendix.html
+func postgresErrorToInfo(code, msg string) (dbErrorInfo, bool) {
+	switch code {
+	case "23505":
+		info := dbErrorInfo{code: http.StatusConflict, message: DBErrUniqueViolation}
+		if m := dbErrPostgresKeyRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.column = m[1]
+		}
+		return info, true
+	case "23503":
+		if strings.Contains(msg, "update or delete") {
+			return dbErrorInfo{code: http.StatusConflict, message: DBErrForeignKeyReferenced}, true
+		}
+		info := dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrForeignKeyNotFound}
+		if m := dbErrPostgresKeyRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.column = m[1]
+		}
+		return info, true
+	case "23502":
+		return dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrNotNullViolation}, true
+	case "23514":
+		return dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrCheckViolation}, true
+	case "40001", "40P01", "55P03":
+		return dbErrorInfo{code: http.StatusServiceUnavailable, message: DBErrSerializationFailure, retryable: true}, true
+	case "57014":
+		return dbErrorInfo{code: http.StatusGatewayTimeout, message: DBErrStatementTimeout}, true
+	}
+	return dbErrorInfo{}, false
+}
+
+// mysqlErrorToInfo recognizes mysql (and mariadb) error by the error number, see https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+func mysqlErrorToInfo(number uint64, msg string) (dbErrorInfo, bool) {
+	switch number {
+	case 1062:
+		// Duplicate entry 'x' for key 'users.email'
+		info := dbErrorInfo{code: http.StatusConflict, message: DBErrUniqueViolation}
+		if m := dbErrQuotedNameRegexp.FindAllStringSubmatch(msg, -1); len(m) > 1 {
+			info.constraint = m[len(m)-1][1]
+		}
+		return info, true
+	case 1451, 1452:
+		info := dbErrorInfo{code: http.StatusConflict, message: DBErrForeignKeyReferenced}
+		if number == 1452 {
+			info = dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrForeignKeyNotFound}
+		}
+		if m := dbErrMySQLForeignKeyRegexp.FindStringSubmatch(msg); len(m) > 2 {
+			info.constraint, info.column = m[1], m[2]
+		}
+		return info, true
+	case 1048, 1364:
+		// Column 'name' cannot be null, Field 'name' doesn't have a default value
+		info := dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrNotNullViolation}
+		if m := dbErrQuotedNameRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.column = m[1]
+		}
+		return info, true
+	case 3819:
+		// Check constraint 'c' is violated.
+		info := dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrCheckViolation}
+		if m := dbErrQuotedNameRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.constraint = m[1]
+		}
+		return info, true
+	case 1205, 1213:
+		return dbErrorInfo{code: http.StatusServiceUnavailable, message: DBErrSerializationFailure, retryable: true}, true
+	case 3024, 1969:
+		return dbErrorInfo{code: http.StatusGatewayTimeout, message: DBErrStatementTimeout}, true
+	}
+	return dbErrorInfo{}, false
+}
+
+// sqlServerErrorToInfo recognizes sqlserver error by the error number, see https://learn.microsoft.com/en-us/sql/relational-databases/errors-events/database-engine-events-and-errors
+func sqlServerErrorToInfo(number int64, msg string) (dbErrorInfo, bool) {
+	switch number {
+	case 2627, 2601:
+		// Violation of UNIQUE KEY constraint 'UQ_x'. ..., Cannot insert duplicate key row in object 'dbo.t' with unique index 'IX_x'. ...
+		info := dbErrorInfo{code: http.StatusConflict, message: DBErrUniqueViolation}
+		if m := dbErrQuotedNameRegexp.FindAllStringSubmatch(msg, -1); len(m) > 0 {
+			info.constraint = m[0][1]
+			if number == 2601 && len(m) > 1 {
+				info.constraint = m[1][1]
+			}
+		}
+		return info, true
+	case 547:
+		// The DELETE statement conflicted with the REFERENCE constraint "FK_x". ..., The INSERT statement conflicted with the FOREIGN KEY constraint "FK_x". ...
+		info := dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrForeignKeyNotFound}
+		if strings.Contains(msg, "REFERENCE constraint") {
+			info = dbErrorInfo{code: http.StatusConflict, message: DBErrForeignKeyReferenced}
+		} else if strings.Contains(msg, "CHECK constraint") {
+			info = dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrCheckViolation}
+		}
+		if m := dbErrQuotedNameRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.constraint = m[1]
+		}
+		if m := dbErrSQLServerColumnRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.column = m[1]
+		}
+		return info, true
+	case 515:
+		// Cannot insert the value NULL into column 'name', table 'db.dbo.t'; column does not allow nulls. ...
+		info := dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrNotNullViolation}
+		if m := dbErrSQLServerColumnRegexp.FindStringSubmatch(msg); len(m) > 1 {
+			info.column = m[1]
+		}
+		return info, true
+	case 1205, 3960:
+		return dbErrorInfo{code: http.StatusServiceUnavailable, message: DBErrSerializationFailure, retryable: true}, true
+	}
+	return dbErrorInfo{}, false
+}
+
+// sqliteErrorToInfo recognizes sqlite error by the message, see https://www.sqlite.org/rescode.html
+func sqliteErrorToInfo(msg string) (dbErrorInfo, bool) {
+	column := func(prefix string) string {
+		_, cols, _ := strings.Cut(msg, prefix)
+		col, _, _ := strings.Cut(strings.TrimSpace(cols), ",")
+		if _, c, ok := strings.Cut(col, "."); ok {
+			return c
+		}
+		return col
+	}
+	switch {
+	case strings.Contains(msg, "UNIQUE constraint failed:"):
+		return dbErrorInfo{code: http.StatusConflict, message: DBErrUniqueViolation, column: column("UNIQUE constraint failed:")}, true
+	case strings.Contains(msg, "FOREIGN KEY constraint failed"):
+		return dbErrorInfo{code: http.StatusConflict, message: DBErrForeignKeyReferenced}, true
+	case strings.Contains(msg, "NOT NULL constraint failed:"):
+		return dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrNotNullViolation, column: column("NOT NULL constraint failed:")}, true
+	case strings.Contains(msg, "CHECK constraint failed"):
+		_, constraint, _ := strings.Cut(msg, "CHECK constraint failed:")
+		return dbErrorInfo{code: http.StatusUnprocessableEntity, message: DBErrCheckViolation, constraint: strings.TrimSpace(constraint)}, true
+	case strings.Contains(msg, "database is locked"), strings.Contains(msg, "database table is locked"):
+		return dbErrorInfo{code: http.StatusServiceUnavailable, message: DBErrSerializationFailure, retryable: true}, true
+	}
+	return dbErrorInfo{}, false
+}
+
+// dbErrorStringField returns the string field value of the driver error struct
+func dbErrorStringField(rv reflect.Value, name string) string {
+	f := rv.FieldByName(name)
+	if f.IsValid() && f.Kind() == reflect.String {
+		return f.String()
+	}
+	return ""
+}
+
+func firstNonEmpty(vals ...string) string {
+	for _, v := range vals {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
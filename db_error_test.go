@@ -0,0 +1,146 @@
+package grest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// the driver errors shape, same as pgconn.PgError, mysql.MySQLError & mssql.Error
+type testPgError struct {
+	Code           string
+	Message        string
+	Detail         string
+	ColumnName     string
+	ConstraintName string
+}
+
+func (e *testPgError) Error() string { return e.Message }
+
+type testMySQLError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *testMySQLError) Error() string { return e.Message }
+
+type testSQLServerError struct {
+	Number  int32
+	Message string
+}
+
+func (e testSQLServerError) Error() string { return e.Message }
+
+func TestTranslateDBError(t *testing.T) {
+	testCases := []struct {
+		err        error
+		code       int
+		constraint string
+		column     string
+	}{
+		{&testPgError{Code: "23505", Message: `duplicate key value violates unique constraint "users_email_key"`, Detail: "Key (email)=(a@b.c) already exists.", ConstraintName: "users_email_key"}, 409, "users_email_key", "email"},
+		{&testPgError{Code: "23503", Message: `update or delete on table "users" violates foreign key constraint "orders_user_id_fkey" on table "orders"`, ConstraintName: "orders_user_id_fkey"}, 409, "orders_user_id_fkey", ""},
+		{&testPgError{Code: "23503", Message: `insert or update on table "orders" violates foreign key constraint "orders_user_id_fkey"`, Detail: `Key (user_id)=(1) is not present in table "users".`, ConstraintName: "orders_user_id_fkey"}, 422, "orders_user_id_fkey", "user_id"},
+		{&testPgError{Code: "23502", Message: `null value in column "name" violates not-null constraint`, ColumnName: "name"}, 422, "", "name"},
+		{&testPgError{Code: "23514", Message: `new row violates check constraint "qty_positive"`, ConstraintName: "qty_positive"}, 422, "qty_positive", ""},
+		{fmt.Errorf("wrapped: %w", &testPgError{Code: "40001", Message: "could not serialize access"}), 503, "", ""},
+		{&testPgError{Code: "57014", Message: "canceling statement due to statement timeout"}, 504, "", ""},
+		{&testMySQLError{Number: 1062, Message: "Duplicate entry 'a@b.c' for key 'users.email'"}, 409, "users.email", ""},
+		{&testMySQLError{Number: 1452, Message: "Cannot add or update a child row: a foreign key constraint fails (`db`.`orders`, CONSTRAINT `fk_user` FOREIGN KEY (`user_id`) REFERENCES `users` (`id`))"}, 422, "fk_user", "user_id"},
+		{&testMySQLError{Number: 1048, Message: "Column 'name' cannot be null"}, 422, "", "name"},
+		{&testMySQLError{Number: 1213, Message: "Deadlock found when trying to get lock"}, 503, "", ""},
+		{&testMySQLError{Number: 3024, Message: "Query execution was interrupted, maximum statement execution time exceeded"}, 504, "", ""},
+		{testSQLServerError{Number: 2627, Message: "Violation of UNIQUE KEY constraint 'UQ_email'. Cannot insert duplicate key in object 'dbo.users'."}, 409, "UQ_email", ""},
+		{testSQLServerError{Number: 547, Message: `The DELETE statement conflicted with the REFERENCE constraint "FK_user". The conflict occurred in database "db", table "dbo.orders", column 'user_id'.`}, 409, "FK_user", "user_id"},
+		{testSQLServerError{Number: 515, Message: "Cannot insert the value NULL into column 'name', table 'db.dbo.users'; column does not allow nulls."}, 422, "", "name"},
+		{testSQLServerError{Number: 1205, Message: "Transaction was deadlocked"}, 503, "", ""},
+		{errors.New("UNIQUE constraint failed: users.email"), 409, "", "email"},
+		{errors.New("NOT NULL constraint failed: users.name"), 422, "", "name"},
+		{errors.New("CHECK constraint failed: qty_positive"), 422, "qty_positive", ""},
+		{errors.New("database is locked"), 503, "", ""},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), 504, "", ""},
+	}
+	for i, tc := range testCases {
+		e, ok := TranslateDBError(tc.err).(*Error)
+		if !ok {
+			t.Errorf("%v. Expected *Error, got [%v]", i, tc.err)
+			continue
+		}
+		if e.Code != tc.code {
+			t.Errorf("%v. Expected code [%v], got [%v]", i, tc.code, e.Code)
+		}
+		detail, _ := e.Detail.(map[string]any)
+		if c, _ := detail["constraint"].(string); c != tc.constraint {
+			t.Errorf("%v. Expected constraint [%v], got [%v]", i, tc.constraint, c)
+		}
+		if c, _ := detail["column"].(string); c != tc.column {
+			t.Errorf("%v. Expected column [%v], got [%v]", i, tc.column, c)
+		}
+		if isRetryable, _ := detail["retryable"].(bool); isRetryable != (tc.code == 503) {
+			t.Errorf("%v. Expected retryable [%v], got [%v]", i, tc.code == 503, isRetryable)
+		}
+	}
+
+	err := errors.New("something else")
+	if TranslateDBError(err) != err {
+		t.Errorf("Expected not recognized error is returned as is")
+	}
+}
+
+func TestDBQueryTranslateDBError(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "orders"`)).
+		WillReturnError(&testPgError{Code: "23505", Message: `duplicate key value violates unique constraint "orders_number_key"`, ConstraintName: "orders_number_key"})
+	mock.ExpectRollback()
+
+	_, err = Create(db, &Order{}, map[string]any{"id": "order-1", "number": "SO-001"})
+	e, ok := err.(*Error)
+	if !ok || e.Code != 409 {
+		t.Fatalf("Expected 409 error, got [%v]", err)
+	}
+	if strings.Contains(e.Message, "INSERT") || strings.Contains(e.Message, "orders_number_key") {
+		t.Errorf("Expected the message does not contain the SQL, got [%v]", e.Message)
+	}
+}
+
+// testLogger records the logged messages
+type testLogger struct {
+	errors []string
+}
+
+func (l *testLogger) Debug(msg string, attrs ...any) {}
+func (l *testLogger) Info(msg string, attrs ...any)  {}
+func (l *testLogger) Warn(msg string, attrs ...any)  {}
+func (l *testLogger) Error(msg string, attrs ...any) {
+	l.errors = append(l.errors, fmt.Sprint(msg, attrs))
+}
+
+func TestDBQueryUnrecognizedDBError(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT`)).
+		WillReturnError(errors.New(`relation "orders" does not exist at SELECT "o"."number" FROM "orders"`))
+
+	logger := &testLogger{}
+	dq := &DBQuery{DB: db, Logger: logger}
+	_, err = dq.Find((&Order{}).GetSchema())
+	e, ok := err.(*Error)
+	if !ok || e.Code != 500 {
+		t.Fatalf("Expected 500 error, got [%v]", err)
+	}
+	if e.Message != DBErrInternal {
+		t.Errorf("Expected the message [%v], got [%v]", DBErrInternal, e.Message)
+	}
+	if len(logger.errors) != 1 || !strings.Contains(logger.errors[0], `relation "orders" does not exist`) {
+		t.Errorf("Expected the original error is logged, got [%v]", logger.errors)
+	}
+}
@@ -2,7 +2,9 @@ package grest
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"slices"
@@ -29,6 +31,16 @@ var (
 	QueryPage              = "$page"                  // ex: /contacts?$page=3&per_page=10           => sql: select * from contacts limit 10 offset 20
 	QueryDisablePagination = "$is_disable_pagination" // ex: /contacts?$is_disable_pagination=true   => sql: select * from contacts
 
+	// cursor (keyset) pagination query params setting
+	// the cursor is an opaque string built from the sort columns (QuerySort or model sorts) plus the primary key
+	// use an empty QueryAfter to get the first page with its next cursor
+	// QueryPage & QueryOffset are ignored when one of them is setted
+	// ex: /contacts?$sort=name&$after=                => sql: select * from contacts order by name, id limit 11
+	// ex: /contacts?$sort=name&$after={next_cursor}   => sql: select * from contacts where (name > 'john') or (name = 'john' and id > 7) order by name, id limit 11
+	// ex: /contacts?$sort=name&$before={prev_cursor}  => sql: select * from contacts where (name < 'john') or (name = 'john' and id < 7) order by name desc, id desc limit 11
+	QueryAfter  = "$after"
+	QueryBefore = "$before"
+
 	// selection query params setting
 	// it can be setted by multiple fields, separated by comma
 	// ex: /contacts?$select=id,code,name    => sql: select id, code, name from contacts
@@ -54,6 +66,8 @@ var (
 	// add prefix - to sort descending
 	// add sufix :i to sort case insensitive
 	// ex: /contacts?$sort=gender,-age,-name:i   => sql: select * from contacts order by gender, age desc, lower(name) desc
+	// aggregate can be sorted using the aggregate key (or count_all for $count), the alias is used if the aggregate is selected
+	// ex: /products?$group=category.id&$select=category.id,$sum:sold&$sort=-$sum:sold   => sql: select category_id, sum(sold) as sum_sold from products group by category_id order by sum_sold desc
 	QuerySort = "$sort"
 
 	// ===== Advance Query Params =====
@@ -64,14 +78,25 @@ var (
 	QueryOr          = "$or"
 	QueryOrDelimiter = "|"
 
+	// filter expression query params setting
+	// support nested logic using and, or, not & parentheses, with eq, ne, gt, ge, lt, le, like & in operators
+	// string value is quoted using single quote, use '' to escape the single quote
+	// ex: /contacts?$filter=(gender eq 'female' or age lt 10) and not (city.id in (1,2))  => sql: select * from contacts where ((gender = 'female' or age < 10) and not (city_id in (1,2)))
+	QueryFilter = "$filter"
+
 	// search query params setting
-	// ex: /contacts?$search=code,name:john     => sql: select * from contacts where (lower(code) = lower('john') or lower(name) = lower('john'))
-	QuerySearch = "$search"
+	// the search is done by the search strategy of the model (see SearchStrategy), the default is case insensitive like
+	// ex: /contacts?$search=code,name:john     => sql: select * from contacts where (lower(code) like lower('%john%') or lower(name) like lower('%john%'))
+	// add QuerySearchRank to QuerySort to sort by the search relevance, if supported by the search strategy
+	// ex: /contacts?$search=name,note:john&$sort=-$search_rank     => sql: select *, ts_rank(...) as "$search_rank" from contacts where to_tsvector(...) @@ plainto_tsquery('john') order by "$search_rank" desc
+	QuerySearch     = "$search"
+	QuerySearchRank = "$search_rank"
 
 	// field query params setting
 	// useful for filter, select or sort using another field
 	// ex: /products?qty_available=$field:qty_on_hand          => sql: select * from products where qty_available = qty_on_hand
 	// ex: /products?qty_on_order.$gt=$field:qty_available     => sql: select * from products where qty_on_order > qty_available
+	// it is disabled unless allowed by QueryPolicy.IsAllowField, the referenced field (the field key or its db column) must be a filterable field
 	QueryField = "$field"
 
 	// aggregation query params
@@ -102,7 +127,13 @@ var (
 	// ex: /contacts?$include=families,friends,phones            => include array fields: families, friends, and phones
 	// ex: /contacts?$include=all                                => include all array fields
 	// ex: /contacts/{id}                                        => same as /contacts?id={id}&$include=all
+	//
+	// the nested array fields can be included using dot notation (limited by QueryLimits.MaxIncludeDepth), and the array fields query params can be scoped using .*.
+	// ex: /customers?$include=orders.items.product_options      => include orders, the items of each order and the product_options of each item
+	// ex: /customers?$include=orders.items&orders.*.items.*.qty.$gt=1&orders.*.$sort=-date => same as above with filtered items and sorted orders
 	QueryInclude = "$include"
+	// number of the streamed rows per array fields query, see FindEach
+	QueryIncludeBatchSize = 100
 	// exclude query params setting
 	// for First method and Find method, by default query for all fields defined in struct is executed
 	// except for fields that explicitly hide by db:"-,hide" struct tags
@@ -110,6 +141,8 @@ var (
 	// it can be setted by multiple fields, separated by comma
 	// ex: /contacts?$exclude=families,friends,phones            => exclude fields: families, friends, and phones
 	QueryExclude = "$exclude"
+	// raw db column filter query params setting, it is disabled unless allowed by QueryPolicy.IsAllowDbField
+	// ex: /contacts?$db_field.c.name=john                      => sql: select * from contacts c where c.name = 'john'
 	QueryDbField = "$db_field"
 
 	// QueryCast defines the delimiter used for casting data types in query parameters.
@@ -125,12 +158,44 @@ func Find(db *gorm.DB, model ModelInterface, query url.Values) ([]map[string]any
 	q := &DBQuery{
 		DB:     db,
 		Model:  model,
-		Schema: model.GetSchema(),
+		Schema: GetSchema(model),
 		Query:  query,
 	}
 	return q.Find(q.Schema, query)
 }
 
+// First finds the first record matching given conditions conds from model and query params
+func First(db *gorm.DB, model ModelInterface, query url.Values) (map[string]any, error) {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+		Query:  query,
+	}
+	return q.First(q.Schema, query)
+}
+
+// FindWithPagination finds all records matching given conditions conds from model and query params, also return the pagination info
+func FindWithPagination(db *gorm.DB, model ModelInterface, query url.Values) ([]map[string]any, PaginationInfo, error) {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+		Query:  query,
+	}
+	return q.FindWithPagination(q.Schema, query)
+}
+
+// PaginationInfo is the pagination info of the Find result
+type PaginationInfo struct {
+	Page       int    `json:"page"`
+	PerPage    int    `json:"per_page"`
+	TotalPages int    `json:"total_pages"`
+	TotalRows  int64  `json:"total_rows"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+}
+
 // DBQuery DBQuery definition for querying with model & query params
 type DBQuery struct {
 	DB     *gorm.DB
@@ -139,6 +204,49 @@ type DBQuery struct {
 	Query  url.Values
 	Data   []map[string]any
 	Err    error
+
+	// filled by Find when QueryAfter or QueryBefore is setted, empty if there is no next or previous page
+	NextCursor string
+	PrevCursor string
+
+	// used by Create, Update, Patch & IsStrict to validate the data, a new Validator (without translator) is used if not setted
+	Validator *Validator
+	Lang      string
+
+	// reject unknown fields, unknown operators and invalid values (based on the field type) of the query params with bad request error,
+	// the valid values are converted to the field type before querying to db
+	IsStrict bool
+
+	// the query cost guardrails, QueryDefaultLimits is used if not setted
+	Limits *QueryLimits
+
+	// cache the Find (and First) result of the model which implements CacheTTL() time.Duration,
+	// the cached results of the table are invalidated by Create, Update, Patch & Delete (see InvalidateCache for the caller's transaction)
+	Cache *Cache
+
+	// scope every query (main table, joined tables, sub queries & array fields) and every write by the tenant column (QueryTenantColumn),
+	// the tenant id of the db context (see ContextWithTenant) is used if not setted
+	TenantID any
+
+	// run Find, First, Count and FindEach on a healthy replica, the reads after a write of this DBQuery (or its context, see ContextWithReadYourWrites)
+	// and the reads inside a transaction are run on DB (the primary)
+	Replicas *Replicas
+
+	// log the DB error which is not recognized by TranslateDBError (the error message is not returned because it may contains SQL or the data),
+	// slog.Default() is used if not setted
+	Logger LoggerInterface
+
+	// prune the joins based on the filters only, see Count
+	isCounting bool
+
+	// skip the QueryPolicy check for the internal query (ex: array fields query), see internal
+	isInternal bool
+
+	// route the reads to the primary after a write, see markWritten
+	isWritten bool
+
+	// select all fields of the schema, see ToSQL
+	isSkipQuerySelect bool
 }
 
 // Find finds all records matching given conditions conds from schema and query params
@@ -148,19 +256,171 @@ func (q *DBQuery) Find(schema map[string]any, qry ...url.Values) ([]map[string]a
 	if len(qry) > 0 {
 		query = qry[0]
 	}
+	cacheKey := q.cacheKey(schema, query)
+	if cacheKey != "" {
+		if cachedRows, ok := q.getCache(cacheKey); ok {
+			return cachedRows, nil
+		}
+	}
+	err := q.read(func() error {
+		var err error
+		rows, err = q.find(schema, query)
+		return err
+	})
+	if err == nil && cacheKey != "" {
+		q.setCache(schema, cacheKey, rows)
+	}
+	return rows, err
+}
+
+// find finds all records matching given conditions conds from schema and query params, see Find
+func (q *DBQuery) find(schema map[string]any, query url.Values) ([]map[string]any, error) {
+	rows := []map[string]any{}
 	db, err := q.Prepare(nil, schema, query)
-	db = q.SetSelect(db, schema, query)
-	db = q.SetOrder(db, schema, query)
-	db = q.SetPagination(db, query)
 	if err != nil {
-		return rows, NewError(http.StatusInternalServerError, err.Error())
+		return rows, q.toError(err)
+	}
+	db = q.SetSelect(db, schema, query)
+	isCursor := q.IsCursorPagination(query)
+	if isCursor {
+		db, err = q.SetCursor(db, schema, query)
+		if err != nil {
+			return rows, err
+		}
+	} else {
+		db = q.SetOrder(db, schema, query)
+		db = q.SetPagination(db, query)
 	}
 	err = db.Find(&rows).Error
 	if err != nil {
-		return rows, NewError(http.StatusInternalServerError, err.Error())
+		return rows, q.toError(err)
+	}
+	if isCursor {
+		rows = q.setCursorResult(query, rows)
+	}
+	if q.isSortedBySearchRank(schema, query) {
+		for _, row := range rows {
+			delete(row, QuerySearchRank)
+		}
 	}
 	rows = q.fixDataType(schema, rows)
-	return q.includeArray(schema, rows)
+	return q.includeArray(schema, query, rows)
+}
+
+// First finds the first record matching given conditions conds from schema and query params
+//
+// all array fields are included if QueryInclude is not setted, and 404 error is returned if no record is found
+func (q *DBQuery) First(schema map[string]any, qry ...url.Values) (map[string]any, error) {
+	query := q.Query
+	if len(qry) > 0 {
+		query = qry[0]
+	}
+	firstQuery := url.Values{}
+	for k, v := range query {
+		firstQuery[k] = v
+	}
+	if _, ok := firstQuery[QueryInclude]; !ok {
+		firstQuery.Set(QueryInclude, "all")
+	}
+	firstQuery.Del(QueryPage)
+	firstQuery.Del(QueryAfter)
+	firstQuery.Del(QueryBefore)
+	firstQuery.Del(QueryDisablePagination)
+	firstQuery.Set(QueryLimit, "1")
+
+	rows, err := q.Find(schema, firstQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, NewError(http.StatusNotFound, "Data is not found")
+	}
+	return rows[0], nil
+}
+
+// FindWithPagination finds all records matching given conditions conds from schema and query params, also return the pagination info
+//
+// if QueryLimit setted to 0, only the counting query is executed
+// if QueryDisablePagination setted to true, the counting query is not executed
+func (q *DBQuery) FindWithPagination(schema map[string]any, qry ...url.Values) ([]map[string]any, PaginationInfo, error) {
+	query := q.Query
+	if len(qry) > 0 {
+		query = qry[0]
+	}
+	info := PaginationInfo{}
+	rows := []map[string]any{}
+	if query.Get(QueryDisablePagination) == "true" {
+		rows, err := q.Find(schema, query)
+		info.Page = 1
+		info.TotalRows = int64(len(rows))
+		if len(rows) > 0 {
+			info.PerPage = len(rows)
+			info.TotalPages = 1
+		}
+		return rows, info, err
+	}
+
+	page, limit := q.GetPageLimit(query)
+	info.Page = page
+	info.PerPage = limit
+	total, err := q.Count(schema, query)
+	if err != nil {
+		return rows, info, err
+	}
+	info.TotalRows = total
+	if limit > 0 {
+		info.TotalPages = int((total + int64(limit) - 1) / int64(limit))
+	}
+	if query.Get(QueryLimit) == "0" {
+		info.PerPage = 0
+		return rows, info, nil
+	}
+
+	rows, err = q.Find(schema, query)
+	if q.IsCursorPagination(query) {
+		info.Page = 0
+		info.NextCursor = q.NextCursor
+		info.PrevCursor = q.PrevCursor
+	}
+	return rows, info, err
+}
+
+// Count counts all records matching given conditions conds from schema and query params
+//
+// the table, joins, where and group are the same as Find, if the query is grouped (or using aggregation) the number of groups is counted
+func (q *DBQuery) Count(schema map[string]any, qry ...url.Values) (int64, error) {
+	var total int64
+	query := q.Query
+	if len(qry) > 0 {
+		query = qry[0]
+	}
+	err := q.read(func() error {
+		var err error
+		total, err = q.count(schema, query)
+		return err
+	})
+	return total, err
+}
+
+// count counts all records matching given conditions conds from schema and query params, see Count
+func (q *DBQuery) count(schema map[string]any, query url.Values) (int64, error) {
+	var total int64
+	// the selected fields are not used when counting ungrouped rows, so the joins are pruned based on the filters only
+	cq := *q
+	cq.isCounting = !q.isGrouped(schema, query)
+	db, err := cq.Prepare(nil, schema, query)
+	if err != nil {
+		return total, q.toError(err)
+	}
+	if q.isGrouped(schema, query) {
+		db = q.SetSelect(db, schema, query)
+		db = q.DB.Session(&gorm.Session{}).Table("(?) AS "+q.Quote("count_query"), db)
+	}
+	err = db.Count(&total).Error
+	if err != nil {
+		return total, q.toError(err)
+	}
+	return total, nil
 }
 
 // fixDataType from db
@@ -217,41 +477,184 @@ func (q *DBQuery) fixDataType(schema map[string]any, rows []map[string]any) []ma
 }
 
 // includeArray include array fields to rows
-func (q *DBQuery) includeArray(schema map[string]any, rows []map[string]any) ([]map[string]any, error) {
-	includeArray := strings.Split(q.Query.Get(QueryInclude), ",")
-	if includeArray[0] != "" {
-		arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
-		arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
-		for i, row := range rows {
-			if includeArray[0] == "all" {
-				for _, arrayKey := range arrayFieldOrder {
-					arrayField, _ := arrayFields[arrayKey]
-					arrayRows, err := q.getArrayRows(arrayKey, arrayField, row)
-					if err != nil {
-						return arrayRows, err
-					}
-					rows[i][arrayKey] = arrayRows
-				}
+func (q *DBQuery) includeArray(schema map[string]any, query url.Values, rows []map[string]any) ([]map[string]any, error) {
+	if len(rows) == 0 {
+		return rows, nil
+	}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	keys, nested := q.includePaths(schema, query.Get(QueryInclude))
+	for _, k := range keys {
+		arrayQuery := query
+		if len(nested[k]) > 0 {
+			// the nested includes are passed down as the scoped include of the array field
+			includes := nested[k]
+			if include := query.Get(k + ".*." + QueryInclude); include != "" {
+				includes = append([]string{include}, includes...)
+			}
+			arrayQuery = url.Values{}
+			for key, val := range query {
+				arrayQuery[key] = val
+			}
+			arrayQuery.Set(k+".*."+QueryInclude, strings.Join(includes, ","))
+		}
+		err := q.setArrayRows(k, arrayFields[k], arrayQuery, rows)
+		if err != nil {
+			return rows, err
+		}
+	}
+	return rows, nil
+}
+
+// setArrayRows include array fields to rows using one query for all rows
+//
+// the array field filter placeholders ({parent_field}) are converted to "in" filter with the values of all rows,
+// then the array rows are stitched back to the parent rows based on the placeholders mapping.
+// if the placeholder is a part of the filter value, the array rows are queried for each row.
+func (q *DBQuery) setArrayRows(arrayKey string, arrayField map[string]any, query url.Values, rows []map[string]any) error {
+	arraySchema, ok := arrayField["schema"].(map[string]any)
+	if !ok {
+		for i := range rows {
+			rows[i][arrayKey] = []map[string]any{}
+		}
+		return nil
+	}
+	arrayFields, _ := arraySchema["fields"].(map[string]map[string]any)
+	arrayFilter, _ := arrayField["filter"].(string)
+	filterQuery, _ := url.ParseQuery(arrayFilter)
+
+	// map of array field key to the parent field key
+	links := map[string]string{}
+	linkKeys := []string{}
+	arrayQuery := url.Values{}
+	isBatch := true
+	for k, vals := range filterQuery {
+		for _, v := range vals {
+			vars := String{}.GetVars(v, "{", "}")
+			if len(vars) == 1 && v == "{"+vars[0]+"}" && arrayFields[k] != nil {
+				links[k] = vars[0]
+				linkKeys = append(linkKeys, k)
+			} else if len(vars) > 0 {
+				isBatch = false
 			} else {
-				for _, k := range includeArray {
-					arrayField, ok := arrayFields[k]
-					if ok {
-						arrayRows, err := q.getArrayRows(k, arrayField, row)
-						if err != nil {
-							return arrayRows, err
-						}
-						rows[i][k] = arrayRows
-					}
+				arrayQuery.Add(k, v)
+			}
+		}
+	}
+	if !isBatch || len(links) == 0 {
+		for i, row := range rows {
+			arrayRows, err := q.getArrayRows(arrayKey, arrayField, query, row)
+			if err != nil {
+				return err
+			}
+			rows[i][arrayKey] = arrayRows
+		}
+		return nil
+	}
+	slices.Sort(linkKeys)
+
+	isEmpty := true
+	for _, k := range linkKeys {
+		values := []string{}
+		for _, row := range rows {
+			val, ok := row[links[k]]
+			if ok && val != nil {
+				v := url.QueryEscape(fmt.Sprintf("%v", val))
+				if !slices.Contains(values, v) {
+					values = append(values, v)
 				}
 			}
 		}
+		if len(values) > 0 {
+			isEmpty = false
+			arrayQuery.Add(k+"."+QueryOptIn, strings.Join(values, ","))
+		}
+	}
+	if isEmpty {
+		for i := range rows {
+			rows[i][arrayKey] = []map[string]any{}
+		}
+		return nil
+	}
+	for key, qs := range query {
+		if strings.HasPrefix(key, arrayKey+".*.") {
+			for _, qv := range qs {
+				arrayQuery.Add(strings.Replace(key, arrayKey+".*.", "", 1), qv)
+			}
+		}
 	}
+	arrayQuery.Set(QueryDisablePagination, "true")
 
-	return rows, nil
+	// the link fields must be selected to stitch the array rows, even if it hidden or not selected
+	removedKeys := []string{}
+	batchFields := map[string]map[string]any{}
+	for k, v := range arrayFields {
+		batchFields[k] = v
+	}
+	for _, k := range linkKeys {
+		if isHide, _ := batchFields[k]["isHide"].(bool); isHide {
+			f := map[string]any{}
+			for fk, fv := range batchFields[k] {
+				f[fk] = fv
+			}
+			f["isHide"] = false
+			batchFields[k] = f
+			removedKeys = append(removedKeys, k)
+		}
+		if querySelect := arrayQuery.Get(QuerySelect); querySelect != "" && !slices.Contains(strings.Split(querySelect, ","), k) {
+			arrayQuery.Set(QuerySelect, querySelect+","+k)
+			removedKeys = append(removedKeys, k)
+		}
+		excludes := strings.Split(arrayQuery.Get(QueryExclude), ",")
+		if i := slices.Index(excludes, k); i >= 0 {
+			arrayQuery.Set(QueryExclude, strings.Join(slices.Delete(excludes, i, i+1), ","))
+			removedKeys = append(removedKeys, k)
+		}
+	}
+	batchSchema := map[string]any{}
+	for k, v := range arraySchema {
+		batchSchema[k] = v
+	}
+	batchSchema["fields"] = batchFields
+
+	arrayRows, err := q.internal().Find(batchSchema, arrayQuery)
+	if err != nil {
+		return err
+	}
+
+	linkValue := func(row map[string]any, keys []string) string {
+		v := strings.Builder{}
+		for _, k := range keys {
+			v.WriteString(fmt.Sprintf("%v", row[k]))
+			v.WriteString("\x00")
+		}
+		return v.String()
+	}
+	arrayRowsByLink := map[string][]map[string]any{}
+	for _, ar := range arrayRows {
+		lv := linkValue(ar, linkKeys)
+		arrayRowsByLink[lv] = append(arrayRowsByLink[lv], ar)
+	}
+	parentKeys := []string{}
+	for _, k := range linkKeys {
+		parentKeys = append(parentKeys, links[k])
+	}
+	for i, row := range rows {
+		ar, ok := arrayRowsByLink[linkValue(row, parentKeys)]
+		if !ok {
+			ar = []map[string]any{}
+		}
+		rows[i][arrayKey] = ar
+	}
+	for _, ar := range arrayRows {
+		for _, k := range removedKeys {
+			delete(ar, k)
+		}
+	}
+	return nil
 }
 
 // getArrayRows include array fields to rows
-func (q *DBQuery) getArrayRows(arrayKey string, arrayField map[string]any, row map[string]any) ([]map[string]any, error) {
+func (q *DBQuery) getArrayRows(arrayKey string, arrayField map[string]any, query url.Values, row map[string]any) ([]map[string]any, error) {
 	arraySchema, ok := arrayField["schema"].(map[string]any)
 	if ok {
 		arrayFilter, _ := arrayField["filter"].(string)
@@ -263,7 +666,7 @@ func (q *DBQuery) getArrayRows(arrayKey string, arrayField map[string]any, row m
 			}
 		}
 		arrayQuery, _ := url.ParseQuery(arrayFilter)
-		for key, qs := range q.Query {
+		for key, qs := range query {
 			if strings.HasPrefix(key, arrayKey+".*.") {
 				for _, qv := range qs {
 					arrayQuery.Add(strings.ReplaceAll(key, arrayKey+".*.", ""), qv)
@@ -271,38 +674,81 @@ func (q *DBQuery) getArrayRows(arrayKey string, arrayField map[string]any, row m
 			}
 		}
 		arrayQuery.Add(QueryDisablePagination, "true")
-		return q.Find(arraySchema, arrayQuery)
+		return q.internal().Find(arraySchema, arrayQuery)
 	}
 	return []map[string]any{}, nil
 }
 
 // ToSQL generate SQL string from schema and query params
+//
+// all fields of the schema are selected (QuerySelect is ignored), because it is used to generate the sub query of the table schema and the relations,
+// empty string is returned and the error is setted to DBQuery.Err if the query params are invalid
 func (q *DBQuery) ToSQL(schema map[string]any, qry ...url.Values) string {
-	return q.DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
+	query := q.Query
+	if len(qry) > 0 {
+		query = qry[0]
+	}
+	sql, err := q.toSQL(schema, query)
+	if err != nil {
+		q.Err = err
+	}
+	return sql
+}
+
+// toSQL generate SQL string from schema and query params, see ToSQL
+func (q *DBQuery) toSQL(schema map[string]any, query url.Values) (string, error) {
+	sq := *q
+	sq.isSkipQuerySelect = true
+	var err error
+	sql := q.DB.ToSQL(func(tx *gorm.DB) *gorm.DB {
 		rows := []map[string]any{}
-		query := q.Query
-		if len(qry) > 0 {
-			query = qry[0]
+		var db *gorm.DB
+		db, err = sq.Prepare(tx, schema, query)
+		if err != nil {
+			return db
 		}
-		db, _ := q.Prepare(tx, schema, query)
-		schema["is_skip_query_select"] = true
-		db = q.SetSelect(db, schema, query)
-		db = q.SetOrder(db, schema, query)
+		db = sq.SetSelect(db, schema, query)
+		db = sq.SetOrder(db, schema, query)
 		return db.Find(&rows)
 	})
+	if err != nil {
+		return "", err
+	}
+	return sql, nil
+}
+
+// subQueryToSQL generate SQL string of the table schema (sub query) using the internal query,
+// the client query params are checked by the parent query, so they are not checked against the table schema
+func (q *DBQuery) subQueryToSQL(tableSchema map[string]any) (string, error) {
+	return q.internal().toSQL(tableSchema, q.Query)
 }
 
 // Prepare prepare gorm.DB for querying with schema & query params
 func (q *DBQuery) Prepare(db *gorm.DB, schema map[string]any, query url.Values) (*gorm.DB, error) {
-	var err error
 	if db == nil {
 		db = q.DB.Session(&gorm.Session{})
 	}
+	if !q.isInternal {
+		if err := q.checkQueryPolicy(schema, query); err != nil {
+			db.AddError(err)
+			return db, err
+		}
+		if q.IsStrict {
+			if err := q.checkStrictQuery(schema, query); err != nil {
+				db.AddError(err)
+				return db, err
+			}
+		}
+		if err := q.checkQueryLimits(schema, query); err != nil {
+			db.AddError(err)
+			return db, err
+		}
+	}
 	db = q.SetTable(db, schema, query)
 	db = q.SetJoin(db, schema, query)
 	db = q.SetWhere(db, schema, query)
 	db = q.SetGroup(db, schema, query)
-	return db, err
+	return db, db.Error
 }
 
 // SetTable specify the table you would like to run db operations
@@ -316,7 +762,12 @@ func (q *DBQuery) SetTable(db *gorm.DB, schema map[string]any, query url.Values)
 	// dynamic from sub query based on client's query params
 	tableSchema, _ := schema["tableSchema"].(map[string]any)
 	if len(tableSchema) > 0 {
-		tableName = "(" + q.ToSQL(tableSchema) + ")"
+		subQuery, err := q.subQueryToSQL(tableSchema)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		tableName = "(" + subQuery + ")"
 	}
 
 	// quote table name if not from sub query
@@ -340,12 +791,16 @@ func (q *DBQuery) SetJoin(db *gorm.DB, schema map[string]any, query url.Values)
 	relationOrder, _ := schema["relationOrder"].([]string)
 	if len(relationOrder) > 0 {
 		relations, _ := schema["relations"].(map[string]map[string]any)
+		joined := q.joinedRelations(schema, query)
 		type joinStr struct {
 			Str  string
 			Args []any
 		}
 		joins := []joinStr{}
 		for _, key := range relationOrder {
+			if !joined[key] {
+				continue
+			}
 			rel, _ := relations[key]
 			joinType, _ := rel["type"].(string)
 			joinType = strings.ToUpper(joinType)
@@ -362,13 +817,19 @@ func (q *DBQuery) SetJoin(db *gorm.DB, schema map[string]any, query url.Values)
 				tableAliasName = tableName
 			}
 
+			conditions, _ := rel["conditions"].([]map[string]any)
+
 			// dynamic from sub query based on client's query params
 			tableSchema, _ := rel["tableSchema"].(map[string]any)
 			if len(tableSchema) > 0 {
-				subQuery := q.ToSQL(tableSchema)
-				if subQuery != "" {
-					tableName = "( " + subQuery + " )"
+				subQuery, err := q.subQueryToSQL(tableSchema)
+				if err != nil {
+					db.AddError(err)
+					return db
 				}
+				tableName = "( " + subQuery + " )"
+			} else if cond := q.tenantCond(tableName, key); cond != nil {
+				conditions = append(slices.Clip(conditions), cond)
 			}
 
 			if tableName != "" {
@@ -380,7 +841,6 @@ func (q *DBQuery) SetJoin(db *gorm.DB, schema map[string]any, query url.Values)
 
 				args := []any{}
 				joinConditions := []string{}
-				conditions, _ := rel["conditions"].([]map[string]any)
 				for _, cond := range conditions {
 					if len(cond) > 0 {
 						joinCondition, arg := q.condToWhereSQL(cond)
@@ -412,6 +872,12 @@ func (q *DBQuery) SetJoin(db *gorm.DB, schema map[string]any, query url.Values)
 // SetWhere specify the where method when querying
 func (q *DBQuery) SetWhere(db *gorm.DB, schema map[string]any, query url.Values) *gorm.DB {
 
+	// filter by the tenant
+	if cond := q.schemaTenantCond(schema); cond != nil {
+		whereSQL, arg := q.condToWhereSQL(cond)
+		db = db.Where(whereSQL, arg)
+	}
+
 	// filter from schema
 	filters, _ := schema["filters"].([]map[string]any)
 	if len(filters) > 0 {
@@ -429,8 +895,21 @@ func (q *DBQuery) SetWhere(db *gorm.DB, schema map[string]any, query url.Values)
 	fields, _ := schema["fields"].(map[string]map[string]any)
 	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
 	for key, val := range query {
+		if cond := q.qsToHavingCond(key, val[0], fields); cond["column1"] != nil {
+			havingSQL, arg := q.condToWhereSQL(cond)
+			if strings.Contains(havingSQL, "?") {
+				db = db.Having(havingSQL, arg)
+			} else {
+				db = db.Having(havingSQL)
+			}
+			continue
+		}
 		cond := q.qsToCond(key, val[0], fields, arrayFields)
-		if cond["column1"] != nil {
+		if err := q.condError(cond); err != nil {
+			db.AddError(err)
+			return db
+		}
+		if cond["column1"] != nil || cond["subQuery"] != nil {
 			whereSQL, arg := q.condToWhereSQL(cond)
 			if strings.Contains(whereSQL, "?") {
 				db = db.Where(whereSQL, arg)
@@ -440,42 +919,18 @@ func (q *DBQuery) SetWhere(db *gorm.DB, schema map[string]any, query url.Values)
 		}
 	}
 
-	// filter from query $search
-	searchKey, searchVal, found := strings.Cut(query.Get(QuerySearch), ":")
-	if !found {
-		searchKey, searchVal, found = strings.Cut(query.Get(QuerySearch), "=")
-	}
-	if found && searchVal != "" {
-		valSearch := strings.Builder{}
-		for i, s := range strings.Split(searchKey, ",") {
-			if i == 0 {
-				valSearch.WriteString(s + "." + QueryOptInsensitiveLike + "=" + searchVal)
-			} else {
-				valSearch.WriteString(QueryOrDelimiter + s + "." + QueryOptInsensitiveLike + "=" + searchVal)
-			}
-		}
-		if valSearch.Len() > 0 {
-			valSearchStr := valSearch.String()
-			orVal, isOrValExists := query[QueryOr]
-			isSearchValExists := isOrValExists
-			if !isSearchValExists {
-				for _, ov := range orVal {
-					if ov == valSearchStr {
-						isSearchValExists = true
-					}
-				}
-			}
-			if !isSearchValExists {
-				query.Add(QueryOr, valSearch.String())
-			}
-		}
+	// filter from query $search using the search strategy of the schema
+	if whereSQL, args := q.searchWhere(schema, query); whereSQL != "" {
+		db = db.Where(whereSQL, args...)
 	}
 
 	// filter from query $or
+	// if one of the $or conditions is aggregate filter, the whole $or group is applied using having
 	orVal, isOrValExists := query[QueryOr]
 	if isOrValExists {
 		for _, ov := range orVal {
 			orDB := q.DB.Session(&gorm.Session{})
+			isHaving := false
 
 			orQueries := []string{}
 			if strings.Contains(ov, "||") {
@@ -491,8 +946,17 @@ func (q *DBQuery) SetWhere(db *gorm.DB, schema map[string]any, query url.Values)
 					orQ, val, found = strings.Cut(orQuery, "=")
 				}
 				if found {
-					cond := q.qsToCond(orQ, val, fields, arrayFields)
+					cond := q.qsToHavingCond(orQ, val, fields)
 					if cond["column1"] != nil {
+						isHaving = true
+					} else {
+						cond = q.qsToCond(orQ, val, fields, arrayFields)
+					}
+					if err := q.condError(cond); err != nil {
+						db.AddError(err)
+						return db
+					}
+					if cond["column1"] != nil || cond["subQuery"] != nil {
 						whereSQL, arg := q.condToWhereSQL(cond)
 						if strings.Contains(whereSQL, "?") {
 							orDB = orDB.Or(whereSQL, arg)
@@ -502,9 +966,28 @@ func (q *DBQuery) SetWhere(db *gorm.DB, schema map[string]any, query url.Values)
 					}
 				}
 			}
-			db = db.Where(orDB)
+			if isHaving {
+				db = db.Having(orDB)
+			} else {
+				db = db.Where(orDB)
+			}
 		}
 	}
+
+	// filter from query $filter
+	if filter := query.Get(QueryFilter); filter != "" {
+		node, err := ParseFilter(filter)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		whereSQL, args, err := q.filterToWhereSQL(node, fields, arrayFields)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		db = db.Where(whereSQL, args...)
+	}
 	return db
 }
 
@@ -512,6 +995,16 @@ func (q *DBQuery) SetWhere(db *gorm.DB, schema map[string]any, query url.Values)
 func (q *DBQuery) qsToCond(key, val string, fields map[string]map[string]any, arrayFields map[string]map[string]any) map[string]any {
 	cond := map[string]any{}
 	key, _ = url.QueryUnescape(key)
+	if q.isScopedQueryParam(key) {
+		return cond
+	}
+	for k, arrayField := range arrayFields {
+		for _, sep := range []string{".0.", ".*."} {
+			if strings.HasPrefix(key, k+sep) {
+				return q.arrayFieldToCond(strings.TrimPrefix(key, k+sep), val, arrayField, fields)
+			}
+		}
+	}
 	subkey := strings.Split(key, ".")
 	lastSubkey := subkey[len(subkey)-1]
 
@@ -543,7 +1036,7 @@ func (q *DBQuery) qsToCond(key, val string, fields map[string]map[string]any, ar
 		cond["column1type"], _ = fields[key]["type"].(string)
 	} else {
 		for k, v := range fields {
-			if strings.HasPrefix(key, k) {
+			if strings.HasPrefix(key, k+".") {
 				cond["column1"], _ = v["db"].(string)
 				fType, _ := v["type"].(string)
 				cond["column1type"] = fType
@@ -552,28 +1045,156 @@ func (q *DBQuery) qsToCond(key, val string, fields map[string]map[string]any, ar
 				}
 			}
 		}
-		if cond["column1"] == nil {
-			// todo : filter from array fields
-			// ?arrayFields.0.field.id={field_id} > where exists (select 1 from array_table at where at.parent_id = parent.id and field_id = {field_id})
-			// ?arrayFields.*.field.id={field_id} > same as above but the array fields response also filtered
-			// for k, v := range arrayFields {
-			// 	// todo
-			// }
+	}
+
+	colVal := strings.Split(val, QueryField+":")
+	if len(colVal) > 1 {
+		cond["column2"] = colVal[1]
+		if db, ok := fields[colVal[1]]["db"].(string); ok {
+			// the field key is referenced, see fieldRefKey
+			cond["column2"] = db
+		}
+	} else {
+		cond["value"] = q.qsToCondValue(operator, val)
+		if q.IsStrict {
+			cond["value"] = q.coerceCondValue(cond)
 		}
 	}
+	return cond
+}
+
+// qsToHavingCond convert aggregate key val query params to schema conditions for having method, return empty map if the key is not aggregate
+//
+// the key is in the following pattern : aggregate[:field[:cast]][.operator]
+// ex: $count.$gt=1, $sum:sold.$gt=0, $avg:price:int.$lte=100
+func (q *DBQuery) qsToHavingCond(key, val string, fields map[string]map[string]any) map[string]any {
+	cond := map[string]any{}
+	key, _ = url.QueryUnescape(key)
+	aggKey, _, _ := strings.Cut(key, QueryCast)
+	aggKey, _, _ = strings.Cut(aggKey, ".")
+	aggFunc := q.qsToAggFuncSQL(aggKey)
+	if aggFunc == "" {
+		return cond
+	}
+	key = strings.TrimPrefix(key, aggKey)
+
+	operator := ""
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		lastSubkey := key[i+1:]
+		operator = q.qsToOptSQL(lastSubkey)
+		if operator != "" {
+			key = key[:i]
+			cond["operator"] = operator
+		}
+		if lastSubkey == QueryOptInsensitiveLike || lastSubkey == QueryOptInsensitiveNotLike {
+			cond["isCaseInsensitive"] = true
+		}
+	}
+
+	fieldKey, castSubkey, _ := strings.Cut(strings.TrimPrefix(key, QueryCast), QueryCast)
+	if fieldKey == "" {
+		if aggKey != QueryCount {
+			return map[string]any{}
+		}
+		cond["column1"] = "COUNT(*)"
+	} else {
+		field, ok := fields[fieldKey]["db"].(string)
+		if !ok {
+			return map[string]any{}
+		}
+		cond["column1"] = aggFunc + "(" + field + ")"
+		if aggKey == QueryMin || aggKey == QueryMax {
+			cond["column1type"], _ = fields[fieldKey]["type"].(string)
+		}
+	}
+	if castSubkey != "" {
+		cond["cast"] = q.qsToStandardSQLDataType(castSubkey)
+	}
 
 	colVal := strings.Split(val, QueryField+":")
 	if len(colVal) > 1 {
 		cond["column2"] = colVal[1]
+		if db, ok := fields[colVal[1]]["db"].(string); ok {
+			// the field key is referenced, see fieldRefKey
+			cond["column2"] = db
+		}
 	} else {
-		vUnescape, err := url.QueryUnescape(val)
+		cond["value"] = q.qsToCondValue(operator, val)
+	}
+	return cond
+}
+
+// qsToCondValue return the unescaped value of the query params,
+// the value of IN & NOT IN is splitted by comma before unescaped, so the value which contains comma is escaped (ex: a%2Cb,c is [a,b c])
+func (q *DBQuery) qsToCondValue(operator, val string) any {
+	unescape := func(v string) string {
+		vUnescape, err := url.QueryUnescape(v)
 		if err != nil {
-			cond["value"] = val
-		} else {
-			cond["value"] = vUnescape
+			return v
 		}
+		return vUnescape
 	}
-	return cond
+	if (operator == "IN" || operator == "NOT IN") && val != "" && strings.ToLower(val) != "null" {
+		values := []string{}
+		for _, v := range strings.Split(val, ",") {
+			values = append(values, unescape(v))
+		}
+		return values
+	}
+	return unescape(val)
+}
+
+// arrayFieldToCond convert array fields query params to where exists condition
+//
+// the array field filter (db struct tag) is used to correlate the array rows with the parent row,
+// the {parent_field} placeholder is replaced by the parent column instead of the parent value.
+//
+//	?arrayFields.0.field.id={field_id} > where exists (select 1 from array_table at where at.parent_id = parent.id and field_id = {field_id})
+//	?arrayFields.*.field.id={field_id} > same as above but the array fields response also filtered (by getArrayRows)
+func (q *DBQuery) arrayFieldToCond(key, val string, arrayField map[string]any, fields map[string]map[string]any) map[string]any {
+	arraySchema, ok := arrayField["schema"].(map[string]any)
+	if !ok {
+		return map[string]any{}
+	}
+	arrayFields, _ := arraySchema["fields"].(map[string]map[string]any)
+	arrayFilter, _ := arrayField["filter"].(string)
+	filterQuery, _ := url.ParseQuery(arrayFilter)
+
+	arrayQuery := url.Values{}
+	correlations := []map[string]any{}
+	for k, vals := range filterQuery {
+		for _, v := range vals {
+			vars := String{}.GetVars(v, "{", "}")
+			if len(vars) == 1 && v == "{"+vars[0]+"}" {
+				column1, _ := arrayFields[k]["db"].(string)
+				column2, _ := fields[vars[0]]["db"].(string)
+				if column1 != "" && column2 != "" {
+					correlations = append(correlations, map[string]any{"column1": column1, "operator": "=", "column2": column2})
+				}
+			} else {
+				arrayQuery.Add(k, v)
+			}
+		}
+	}
+	arrayQuery.Add(key, val)
+
+	db, err := q.internal().Prepare(nil, arraySchema, arrayQuery)
+	if err != nil {
+		return map[string]any{"subQuery": db}
+	}
+	for _, c := range correlations {
+		whereSQL, _ := q.condToWhereSQL(c)
+		db = db.Where(whereSQL)
+	}
+	return map[string]any{"subQuery": db.Select("1")}
+}
+
+// condError returns the error of the sub query condition (ex: invalid filter of the array field), see arrayFieldToCond
+func (q *DBQuery) condError(cond map[string]any) error {
+	if subQuery, ok := cond["subQuery"].(*gorm.DB); ok {
+		return subQuery.Error
+	}
+	return nil
 }
 
 // qsToOptSQL return sql operator from part of query params key
@@ -598,37 +1219,17 @@ func (q *DBQuery) qsToOptSQL(key string) string {
 
 // qsToStandardSQLDataType return sql data type from part of query params key
 func (q *DBQuery) qsToStandardSQLDataType(key string) string {
-	opt := map[string]StandardDataType{
-		"int":       {Default: "INT"},
-		"bigint":    {Default: "BIGINT"},
-		"float":     {Default: "FLOAT"},
-		"double":    {Default: "DOUBLE"},
-		"decimal":   {Default: "DECIMAL"},
-		"char":      {Default: "CHAR"},
-		"varchar":   {Default: "VARCHAR"},
-		"text":      {Default: "TEXT"},
-		"date":      {Default: "DATE"},
-		"time":      {Default: "TIME"},
-		"timestamp": {Default: "TIMESTAMP"},
-		"boolean":   {Default: "BOOLEAN"},
-		"binary":    {Default: "BINARY"},
-		"blob":      {Default: "BLOB"},
-		"json":      {Default: "JSON"},
-	}
-	res := ""
-	if dataType, ok := opt[key]; ok {
-		res = dataType.Alternatives[q.DB.Dialector.Name()]
-		if res == "" {
-			return dataType.Default
-		}
-	}
-	return res
+	return q.Dialect().DataType(key)
 }
 
 // condToWhereSQL convert schema conditions to where method SQL string
 func (q *DBQuery) condToWhereSQL(cond map[string]any) (string, any) {
 	where := strings.Builder{}
 
+	if subQuery, ok := cond["subQuery"].(*gorm.DB); ok {
+		return "EXISTS (?)", subQuery
+	}
+
 	cast, _ := cond["cast"].(string)
 	column1, _ := cond["column1"].(string)
 	column2, _ := cond["column2"].(string)
@@ -640,9 +1241,16 @@ func (q *DBQuery) condToWhereSQL(cond map[string]any) (string, any) {
 	argStr, _ := arg.(string)
 	isNullSQL := false
 
+	dialect := q.Dialect()
 	isOperatorIN := strings.Contains(strings.ToUpper(operator), "IN")
 	isOperatorLIKE := strings.Contains(strings.ToUpper(operator), "LIKE")
 	isCaseInsensitive, _ := cond["isCaseInsensitive"].(bool)
+	if isCaseInsensitive && isOperatorLIKE {
+		if iLike := dialect.ILike(operator); iLike != "" {
+			operator = iLike
+			isCaseInsensitive = false
+		}
+	}
 
 	column1jsonKey, _ := cond["column1jsonKey"].(string)
 	column1type, _ := cond["column1type"].(string)
@@ -660,10 +1268,10 @@ func (q *DBQuery) condToWhereSQL(cond map[string]any) (string, any) {
 		if column1jsonKey == "" && !strings.Contains(column1, " ") && !strings.Contains(column1, "(") {
 			column1 = q.DB.Statement.Quote(column1)
 		}
-		if q.DB.Dialector.Name() == "postgres" && isOperatorLIKE && (!column1isString || isInvalidUUID) {
-			column1 = "CAST(" + column1 + " AS text)"
+		if isOperatorLIKE && (!column1isString || isInvalidUUID) {
+			column1 = dialect.LikeColumn(column1)
 		} else if cast != "" {
-			column1 = "CAST(" + column1 + " AS " + cast + ")"
+			column1 = dialect.Cast(column1, cast)
 		}
 		if isCaseInsensitive {
 			column1 = "LOWER(" + column1 + ")"
@@ -679,11 +1287,7 @@ func (q *DBQuery) condToWhereSQL(cond map[string]any) (string, any) {
 		}
 		where.WriteString(" NULL")
 	} else if isOperatorIN || isOperatorLIKE {
-		sqlInLikeOpt := strings.ToUpper(operator)
-		if !strings.Contains(operator, " ") {
-			sqlInLikeOpt = " " + sqlInLikeOpt + " "
-		}
-		where.WriteString(sqlInLikeOpt)
+		where.WriteString(" " + strings.TrimSpace(strings.ToUpper(operator)) + " ")
 	} else {
 		where.WriteString(operator)
 	}
@@ -703,11 +1307,10 @@ func (q *DBQuery) condToWhereSQL(cond map[string]any) (string, any) {
 			column2 = q.DB.Statement.Quote(column2)
 		}
 
-		//special case for postgresql, func like is only for string
-		if q.DB.Dialector.Name() == "postgres" && isOperatorLIKE && (!column2isString || column2isInvalidUUID) {
-			column2 = "CAST(" + column2 + " AS text)"
+		if isOperatorLIKE && (!column2isString || column2isInvalidUUID) {
+			column2 = dialect.LikeColumn(column2)
 		} else if cast != "" {
-			column2 = "CAST(" + column2 + " AS " + cast + ")"
+			column2 = dialect.Cast(column2, cast)
 		}
 		if isCaseInsensitive {
 			column2 = "LOWER(" + column2 + ")"
@@ -745,19 +1348,19 @@ func (q *DBQuery) condToWhereSQL(cond map[string]any) (string, any) {
 func (q *DBQuery) SetGroup(db *gorm.DB, schema map[string]any, query url.Values) *gorm.DB {
 	fields, _ := schema["fields"].(map[string]map[string]any)
 
-	// group from schema
-	groups, _ := schema["groups"].(map[string]string)
+	// group from schema, copied so the query groups are not added to the schema
+	groups := map[string]string{}
+	schemaGroups, _ := schema["groups"].(map[string]string)
+	for k, v := range schemaGroups {
+		groups[k] = v
+	}
 
 	// group from query $group
 	queryGroups := strings.Split(query.Get(QueryGroup), ",")
 	for _, qg := range queryGroups {
 		group, ok := fields[qg]["db"].(string)
 		if ok {
-			if groups != nil {
-				groups[qg] = group
-			} else {
-				groups = map[string]string{qg: group}
-			}
+			groups[qg] = group
 		}
 	}
 
@@ -776,11 +1379,7 @@ func (q *DBQuery) SetGroup(db *gorm.DB, schema map[string]any, query url.Values)
 			for _, k := range querySelect {
 				group, ok := fields[k]["db"].(string)
 				if ok {
-					if groups != nil {
-						groups[k] = group
-					} else {
-						groups = map[string]string{k: group}
-					}
+					groups[k] = group
 				}
 			}
 		}
@@ -798,12 +1397,23 @@ func (q *DBQuery) SetGroup(db *gorm.DB, schema map[string]any, query url.Values)
 
 // SetSelect specify fields that you want when querying
 func (q *DBQuery) SetSelect(db *gorm.DB, schema map[string]any, query url.Values) *gorm.DB {
+	selectedFields := q.getSelect(schema, query)
+	if q.isSortedBySearchRank(schema, query) {
+		rankSQL, args := q.searchRank(schema, query)
+		selectedFields = append(selectedFields, rankSQL+" AS "+q.Quote(QuerySearchRank))
+		return db.Select(strings.Join(selectedFields, ", "), args...)
+	}
+	return db.Select(strings.Join(selectedFields, ", "))
+}
+
+// getSelect return quoted select SQL strings from schema and query params
+func (q *DBQuery) getSelect(schema map[string]any, query url.Values) []string {
 	selectedFields := []string{}
 	fieldOrder, _ := schema["fieldOrder"].([]string)
 	fields, _ := schema["fields"].(map[string]map[string]any)
 	querySelect := strings.Split(query.Get(QuerySelect), ",")
 	querySelect = append(querySelect, strings.Split(query.Get(QueryGroup), ",")...)
-	if len(querySelect) > 0 && schema["is_skip_query_select"] == nil {
+	if len(querySelect) > 0 && !q.isSkipQuerySelect {
 		for _, k := range querySelect {
 			field, ok := fields[k]["db"].(string)
 			if ok {
@@ -845,7 +1455,7 @@ func (q *DBQuery) SetSelect(db *gorm.DB, schema map[string]any, query url.Values
 			}
 		}
 	}
-	return db.Select(strings.Join(selectedFields, ", "))
+	return selectedFields
 }
 
 // qsToAggFuncSQL return aggregate function SQL string from part of query params value
@@ -872,8 +1482,20 @@ func (q *DBQuery) addSelect(selectedFields []string, field, alias string) []stri
 
 // SetOrder specify order method when retrieve records
 func (q *DBQuery) SetOrder(db *gorm.DB, schema map[string]any, query url.Values) *gorm.DB {
+	for _, srt := range q.getSorts(schema, query) {
+		orderBySQL := q.sortToOrderBySQL(srt)
+		if orderBySQL != "" {
+			db = db.Order(orderBySQL)
+		}
+	}
+	return db
+}
+
+// getSorts return effective sorts from query params and schema sorts
+func (q *DBQuery) getSorts(schema map[string]any, query url.Values) []map[string]any {
 	fields, _ := schema["fields"].(map[string]map[string]any)
 	sorts, _ := schema["sorts"].([]map[string]any)
+	effectiveSorts := []map[string]any{}
 	hasQuerySort := false
 	querySorts := strings.Split(query.Get(QuerySort), ",")
 	for _, s := range querySorts {
@@ -887,14 +1509,24 @@ func (q *DBQuery) SetOrder(db *gorm.DB, schema map[string]any, query url.Values)
 			srt["isCaseInsensitive"] = true
 		}
 		field, ok := fields[s]["db"].(string)
-		if ok {
+		if s == QuerySearchRank {
+			// the search rank is selected by SetSelect
+			if q.isSortedBySearchRank(schema, query) {
+				srt["column"] = QuerySearchRank
+				srt["alias"] = QuerySearchRank
+			}
+		} else if aggSort := q.qsToAggSort(s, fields, query); aggSort != nil {
+			for k, v := range aggSort {
+				srt[k] = v
+			}
+		} else if ok {
 			srt["column"] = field
 		} else {
 			for k, v := range fields {
-				if strings.HasPrefix(s, k) {
-					srt["column"] = k
+				if strings.HasPrefix(s, k+".") {
 					fType, ok := v["type"].(string)
 					if ok && strings.Contains(strings.ToLower(fType), "json") {
+						srt["column"], _ = v["db"].(string)
 						srt["jsonKey"] = strings.Replace(s, k+".", "", 1)
 					}
 				}
@@ -907,56 +1539,107 @@ func (q *DBQuery) SetOrder(db *gorm.DB, schema map[string]any, query url.Values)
 
 		if srt["column"] != nil {
 			hasQuerySort = true
-			orderBySQL := q.sortToOrderBySQL(srt)
-			if orderBySQL != "" {
-				db = db.Order(orderBySQL)
-			}
+			effectiveSorts = append(effectiveSorts, srt)
 		}
 	}
 
 	for _, srt := range sorts {
 		isRequired, _ := srt["isRequired"].(bool)
 		if isRequired || !hasQuerySort {
-			orderBySQL := q.sortToOrderBySQL(srt)
-			if orderBySQL != "" {
-				db = db.Order(orderBySQL)
+			column, _ := srt["column"].(string)
+			if aggSort := q.qsToAggSort(column, fields, query); aggSort != nil {
+				aggSrt := map[string]any{}
+				for k, v := range srt {
+					aggSrt[k] = v
+				}
+				for k, v := range aggSort {
+					aggSrt[k] = v
+				}
+				srt = aggSrt
 			}
+			effectiveSorts = append(effectiveSorts, srt)
 		}
 	}
 
-	return db
+	return effectiveSorts
+}
+
+// qsToAggSort return the sort of aggregate key (ex: $sum:sold, $count or count_all), return nil if the key is not aggregate
+//
+// the alias generated by SetSelect is used if the aggregate is selected, otherwise the aggregate expression is used
+func (q *DBQuery) qsToAggSort(key string, fields map[string]map[string]any, query url.Values) map[string]any {
+	if key == "count_all" {
+		key = QueryCount
+	}
+	agg := strings.Split(key, ":")
+	aggFunc := q.qsToAggFuncSQL(agg[0])
+	if aggFunc == "" {
+		return nil
+	}
+	srt := map[string]any{}
+	if len(agg) > 1 {
+		field, ok := fields[agg[1]]["db"].(string)
+		if !ok {
+			return nil
+		}
+		srt["column"] = aggFunc + "(" + field + ")"
+		srt["alias"] = strings.ToLower(aggFunc) + "_" + agg[1]
+		srt["type"] = "NullFloat64"
+		if agg[0] == QueryMin || agg[0] == QueryMax {
+			srt["type"], _ = fields[agg[1]]["type"].(string)
+		}
+	} else if agg[0] == QueryCount {
+		srt["column"] = "COUNT(*)"
+		srt["alias"] = "count_all"
+		srt["type"] = "NullInt64"
+	} else {
+		return nil
+	}
+	if !slices.Contains(strings.Split(query.Get(QuerySelect), ","), key) {
+		delete(srt, "alias")
+	}
+	return srt
 }
 
 // sortToOrderBySQL convert schema sorts to order by method SQL string
 func (q *DBQuery) sortToOrderBySQL(srt map[string]any) string {
+	column := q.sortToColumnSQL(srt)
+	if column == "" {
+		return column
+	}
+	direction, _ := srt["direction"].(string)
+	if direction == "" {
+		direction = "ASC"
+	}
+
+	return column + " " + strings.ToUpper(direction)
+}
+
+// sortToColumnSQL convert schema sorts to the sorted column SQL string (without direction)
+func (q *DBQuery) sortToColumnSQL(srt map[string]any) string {
 	column, _ := srt["column"].(string)
 	if column == "" {
 		return column
 	}
+	isCaseInsensitive, _ := srt["isCaseInsensitive"].(bool)
+	dtType, _ := srt["type"].(string)
+	isString := strings.Contains(strings.ToLower(dtType), "string") || strings.Contains(strings.ToLower(dtType), "text")
+
+	// selected aggregate is sorted by its alias, except for case insensitive sort because alias can not be used in expression
+	if alias, _ := srt["alias"].(string); alias != "" && (!isCaseInsensitive || !isString) {
+		return q.Quote(alias)
+	}
 	jsonKey, _ := srt["jsonKey"].(string)
 	if jsonKey != "" {
 		column = q.QuoteJSON(column, jsonKey)
 	} else if !strings.Contains(column, " ") && !strings.Contains(column, "(") {
 		column = q.DB.Statement.Quote(column)
 	}
-	isCaseInsensitive, _ := srt["isCaseInsensitive"].(bool)
-	if isCaseInsensitive {
-		columnVal := column
-		column = "LOWER(" + columnVal + ")"
-		//special case because in postgresql func lower only for string/text
-		if q.DB.Dialector.Name() == "postgres" {
-			dtType, ok := srt["type"].(string)
-			if ok && (!strings.Contains(strings.ToLower(dtType), "string") && !strings.Contains(strings.ToLower(dtType), "text")) {
-				column = columnVal
-			}
-		}
+	// lower is only for string/text
+	if isCaseInsensitive && (dtType == "" || isString) {
+		column = "LOWER(" + column + ")"
 	}
-	direction, _ := srt["direction"].(string)
-	if direction == "" {
-		direction = "ASC"
-	}
-
-	return column + " " + strings.ToUpper(direction)
+	return column
 }
 
 // SetPagination specify limit & offset method when retrieve records
@@ -999,55 +1682,76 @@ func (q *DBQuery) GetPageLimit(qry ...url.Values) (int, int) {
 	return page, limit
 }
 
+// primaryKey return the field key of the primary key based on gorm "primaryKey" tag, or "id" field if not setted
+func (q *DBQuery) primaryKey(schema map[string]any) string {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	fieldOrder, _ := schema["fieldOrder"].([]string)
+	for _, k := range fieldOrder {
+		gormTag, _ := fields[k]["gorm"].(string)
+		gormTag = strings.ToLower(gormTag)
+		if strings.Contains(gormTag, "primarykey") || strings.Contains(gormTag, "primary_key") {
+			return k
+		}
+	}
+	if fields["id"] != nil {
+		return "id"
+	}
+	return ""
+}
+
+// isGrouped return true if the query is grouped by schema groups, $group or aggregate $select
+func (q *DBQuery) isGrouped(schema map[string]any, query url.Values) bool {
+	groups, _ := schema["groups"].(map[string]string)
+	if len(groups) > 0 {
+		return true
+	}
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	for _, k := range strings.Split(query.Get(QueryGroup), ",") {
+		if fields[k] != nil {
+			return true
+		}
+	}
+	for _, k := range strings.Split(query.Get(QuerySelect), ",") {
+		agg := strings.Split(k, ":")
+		if q.qsToAggFuncSQL(agg[0]) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// toError return err as is if it is *Error (ex: invalid query params), the recognized DB error is translated by TranslateDBError,
+// otherwise the error is logged and return internal server error with DBErrInternal message
+func (q *DBQuery) toError(err error) error {
+	err = TranslateDBError(err)
+	e := &Error{}
+	if errors.As(err, &e) {
+		return e
+	}
+	logger := q.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Error("DBQuery Error", "error", err.Error())
+	return NewError(http.StatusInternalServerError, DBErrInternal)
+}
+
+// Dialect returns the registered Dialect of the current using database
+func (q DBQuery) Dialect() Dialect {
+	return GetDialect(q.DB.Dialector.Name())
+}
+
 // Quote returns quoted SQL string
 func (q DBQuery) Quote(text string) string {
-	switch q.DB.Dialector.Name() {
-	case "sqlite", "mysql":
-		return "`" + text + "`"
-	case "postgres", "sqlserver", "firebird":
-		return `"` + text + `"`
-	default:
-		return `"` + text + `"`
-	}
+	return q.Dialect().Quote(text)
 }
 
 // QuoteJSON returns quoted json extract SQL string for json column with json key
 func (q DBQuery) QuoteJSON(column, jsonKey string) string {
-	switch q.DB.Dialector.Name() {
-	case "mysql", "sqlite":
-		return "JSON_EXTRACT(" + q.DB.Statement.Quote(column) + ",'$." + jsonKey + "')"
-	case "sqlserver":
-		return "JSON_VALUE(" + q.DB.Statement.Quote(column) + ",'$." + jsonKey + "')"
-	case "postgres":
-		jsonPath := strings.Builder{}
-		keys := strings.Split(jsonKey, ".")
-		for idx, key := range keys {
-			if idx > 0 {
-				jsonPath.WriteString(",")
-			}
-			jsonPath.WriteString("'" + key + "'")
-		}
-		return "json_extract_path_text(" + q.DB.Statement.Quote(column) + "::json," + jsonPath.String() + ")"
-	default:
-		// unsupported json
-		return q.DB.Statement.Quote(column)
-	}
+	return q.Dialect().QuoteJSON(q.DB.Statement.Quote(column), jsonKey)
 }
 
 // NewUUIDSQL returns uuid SQL string
 func (q DBQuery) NewUUIDSQL() string {
-	switch q.DB.Dialector.Name() {
-	case "sqlite":
-		return "lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))),2) || '-' || substr('89ab',abs(random()) % 4 + 1, 1) || substr(lower(hex(randomblob(2))),2) || '-' || lower(hex(randomblob(6)))"
-	case "mysql":
-		return "UUID()"
-	case "postgres":
-		return "md5(random()::text || clock_timestamp()::text)::uuid"
-	case "sqlserver":
-		return "LOWER(CAST(NEWID() AS CHAR(36)))"
-	case "firebird":
-		return "LOWER(UUID_TO_CHAR(GEN_UUID()))"
-	default:
-		return ""
-	}
+	return q.Dialect().NewUUIDSQL()
 }
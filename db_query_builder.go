@@ -0,0 +1,228 @@
+package grest
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Operator is the filter operator of QueryBuilder, compiled to the query params operator (ex: Gt to QueryOptGreaterThan)
+type Operator int
+
+const (
+	Eq       Operator = iota // QueryOptEqual
+	Ne                       // QueryOptNotEqual
+	Gt                       // QueryOptGreaterThan
+	Gte                      // QueryOptGreaterThanOrEqual
+	Lt                       // QueryOptLowerThan
+	Lte                      // QueryOptLowerThanOrEqual
+	Like                     // QueryOptLike
+	NotLike                  // QueryOptNotLike
+	ILike                    // QueryOptInsensitiveLike
+	NotILike                 // QueryOptInsensitiveNotLike
+	In                       // QueryOptIn
+	NotIn                    // QueryOptNotIn
+)
+
+// String returns the query params operator
+func (o Operator) String() string {
+	switch o {
+	case Ne:
+		return QueryOptNotEqual
+	case Gt:
+		return QueryOptGreaterThan
+	case Gte:
+		return QueryOptGreaterThanOrEqual
+	case Lt:
+		return QueryOptLowerThan
+	case Lte:
+		return QueryOptLowerThanOrEqual
+	case Like:
+		return QueryOptLike
+	case NotLike:
+		return QueryOptNotLike
+	case ILike:
+		return QueryOptInsensitiveLike
+	case NotILike:
+		return QueryOptInsensitiveNotLike
+	case In:
+		return QueryOptIn
+	case NotIn:
+		return QueryOptNotIn
+	}
+	return QueryOptEqual
+}
+
+// Cond is the filter condition of QueryBuilder
+type Cond struct {
+	Field    string
+	Operator Operator
+	Value    any
+}
+
+// C returns the filter condition, used by QueryBuilder.Or
+func C(field string, op Operator, value any) Cond {
+	return Cond{Field: field, Operator: op, Value: value}
+}
+
+// QueryBuilder builds query params programmatically, so the query is compiled by the same path as the client's query params
+//
+// example :
+//
+//	rows, err := grest.Q(&Contact{}).
+//		Where("age", grest.Gt, 18).
+//		Or(grest.C("gender", grest.Eq, "female"), grest.C("is_employee", grest.Eq, true)).
+//		Select("id", "name").
+//		Sort("-name").
+//		Include("phones").
+//		Find(db)
+type QueryBuilder struct {
+	Model ModelInterface
+	Query url.Values
+}
+
+// Q returns a new QueryBuilder of the model
+func Q(model ModelInterface) *QueryBuilder {
+	return &QueryBuilder{Model: model, Query: url.Values{}}
+}
+
+// Where adds filter condition, multiple conditions are combined using and
+func (b *QueryBuilder) Where(field string, op Operator, value any) *QueryBuilder {
+	b.Query.Add(field+"."+op.String(), b.formatValue(value))
+	return b
+}
+
+// Or adds a group of filter conditions combined using or (QueryOr), multiple groups are combined using and
+func (b *QueryBuilder) Or(conds ...Cond) *QueryBuilder {
+	orQueries := []string{}
+	for _, c := range conds {
+		orQueries = append(orQueries, c.Field+"."+c.Operator.String()+":"+b.formatValue(c.Value))
+	}
+	if len(orQueries) > 0 {
+		b.Query.Add(QueryOr, strings.Join(orQueries, QueryOrDelimiter))
+	}
+	return b
+}
+
+// Filter sets the filter expression (QueryFilter)
+func (b *QueryBuilder) Filter(expr string) *QueryBuilder {
+	b.Query.Set(QueryFilter, expr)
+	return b
+}
+
+// Search sets the search value on the fields (QuerySearch)
+func (b *QueryBuilder) Search(value string, fields ...string) *QueryBuilder {
+	b.Query.Set(QuerySearch, strings.Join(fields, ",")+":"+value)
+	return b
+}
+
+// Select sets the selected fields (QuerySelect)
+func (b *QueryBuilder) Select(fields ...string) *QueryBuilder {
+	return b.setList(QuerySelect, fields)
+}
+
+// Sort sets the sorted fields (QuerySort), add prefix - to sort descending
+func (b *QueryBuilder) Sort(fields ...string) *QueryBuilder {
+	return b.setList(QuerySort, fields)
+}
+
+// Group sets the grouped fields (QueryGroup)
+func (b *QueryBuilder) Group(fields ...string) *QueryBuilder {
+	return b.setList(QueryGroup, fields)
+}
+
+// Include sets the included array fields (QueryInclude)
+func (b *QueryBuilder) Include(fields ...string) *QueryBuilder {
+	return b.setList(QueryInclude, fields)
+}
+
+// Exclude sets the excluded fields (QueryExclude)
+func (b *QueryBuilder) Exclude(fields ...string) *QueryBuilder {
+	return b.setList(QueryExclude, fields)
+}
+
+// Page sets the page (QueryPage) and the number of rows per page (QueryLimit)
+func (b *QueryBuilder) Page(page, perPage int) *QueryBuilder {
+	b.Query.Set(QueryPage, strconv.Itoa(page))
+	b.Query.Set(QueryLimit, strconv.Itoa(perPage))
+	return b
+}
+
+// DisablePagination disables the pagination (QueryDisablePagination)
+func (b *QueryBuilder) DisablePagination() *QueryBuilder {
+	b.Query.Set(QueryDisablePagination, "true")
+	return b
+}
+
+// Values returns the built query params
+func (b *QueryBuilder) Values() url.Values {
+	return b.Query
+}
+
+// Find finds all records matching the built query params, see Find
+func (b *QueryBuilder) Find(db *gorm.DB) ([]map[string]any, error) {
+	return Find(db, b.Model, b.Query)
+}
+
+// First finds the first record matching the built query params, see First
+func (b *QueryBuilder) First(db *gorm.DB) (map[string]any, error) {
+	return First(db, b.Model, b.Query)
+}
+
+// FindWithPagination finds all records matching the built query params with the pagination info, see FindWithPagination
+func (b *QueryBuilder) FindWithPagination(db *gorm.DB) ([]map[string]any, PaginationInfo, error) {
+	return FindWithPagination(db, b.Model, b.Query)
+}
+
+// Count counts all records matching the built query params
+func (b *QueryBuilder) Count(db *gorm.DB) (int64, error) {
+	q := &DBQuery{DB: db, Model: b.Model, Schema: GetSchema(b.Model), Query: b.Query}
+	return q.Count(q.Schema, b.Query)
+}
+
+func (b *QueryBuilder) setList(key string, fields []string) *QueryBuilder {
+	if len(fields) > 0 {
+		b.Query.Set(key, strings.Join(fields, ","))
+	}
+	return b
+}
+
+// formatValue returns query params value of the Go value, the value is escaped because the query params value is unescaped by qsToCond,
+// the slice is joined by comma (for In & NotIn) after each value is escaped (including its comma), nil is null
+func (b *QueryBuilder) formatValue(value any) string {
+	if value == nil {
+		return "null"
+	}
+	if valuer, ok := value.(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil || v == nil {
+			return "null"
+		}
+		value = v
+	}
+	switch v := value.(type) {
+	case string:
+		return url.QueryEscape(v)
+	case []byte:
+		return url.QueryEscape(string(v))
+	case time.Time:
+		return url.QueryEscape(v.Format(time.RFC3339Nano))
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		vals := []string{}
+		for i := 0; i < rv.Len(); i++ {
+			vals = append(vals, b.formatValue(rv.Index(i).Interface()))
+		}
+		return strings.Join(vals, ",")
+	}
+	return url.QueryEscape(fmt.Sprintf("%v", value))
+}
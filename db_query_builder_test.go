@@ -0,0 +1,73 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	schema := (&Article{}).GetSchema()
+
+	b := Q(&Article{}).
+		Where("total_review", Gt, 18).
+		Where("title", ILike, "50% off|sale").
+		Where("author.id", In, []string{"a", "b"}).
+		Where("content", NotIn, []string{"foo, bar", "baz"}).
+		Where("deleted_at", Eq, nil).
+		Or(C("is_active", Eq, true), C("created_at", Gte, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC))).
+		Select("id", "title").
+		Sort("-title").
+		Include("categories")
+
+	query := b.Values()
+	for key, expected := range map[string]string{
+		"total_review.$gt": "18",
+		"title.$ilike":     "50%25+off%7Csale",
+		"author.id.$in":    "a,b",
+		"content.$nin":     "foo%2C+bar,baz",
+		"deleted_at.$eq":   "null",
+		QueryOr:            "is_active.$eq:true|created_at.$gte:2024-01-02T00%3A00%3A00Z",
+		QuerySelect:        "id,title",
+		QuerySort:          "-title",
+		QueryInclude:       "categories",
+	} {
+		if query.Get(key) != expected {
+			t.Errorf("Expected %v [%v], got [%v]", key, expected, query.Get(key))
+		}
+	}
+
+	sql := dq.ToSQL(schema, query)
+	expectedSQL := dq.ToSQL(schema, url.Values{
+		"total_review.$gt": {"18"},
+		"title.$ilike":     {"50% off|sale"},
+		"author.id.$in":    {"a,b"},
+		"content.$nin":     {"foo%2C bar,baz"},
+		"deleted_at.$eq":   {"null"},
+		QueryOr:            {"is_active.$eq:true|created_at.$gte:2024-01-02T00:00:00Z"},
+		QuerySelect:        {"id,title"},
+		QuerySort:          {"-title"},
+	})
+	for _, expected := range []string{
+		`coalesce(tr.total_review,0)>'18'`,
+		`"a"."title" ILIKE '50% off|sale'`,
+		`"a"."author_id" IN ('a','b')`,
+		`"a"."content" NOT IN ('foo, bar','baz')`,
+		`"a"."deleted_at" IS NULL`,
+		`("a"."is_active"='1' OR "a"."created_at">='2024-01-02T00:00:00Z')`,
+		`ORDER BY "a"."title" DESC`,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected [%v] in:\n%v", expected, sql)
+		}
+		if !strings.Contains(expectedSQL, expected) {
+			t.Errorf("Expected [%v] in the query params SQL:\n%v", expected, expectedSQL)
+		}
+	}
+}
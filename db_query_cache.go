@@ -0,0 +1,205 @@
+package grest
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// QueryCacheDependencyPrefix is the cache key prefix of the cached tables which depend on the table (joined or included),
+// used to invalidate the cached query results of the dependent tables when the table is written
+var QueryCacheDependencyPrefix = "grest.cache_dependency."
+
+// cacheKey returns the cache key of the Find result (table name + normalized query params), empty string if the result is not cached
+//
+// the result is cached only if DBQuery.Cache is setted and the model implements CacheTTL() time.Duration which returns positive duration,
+// the internal query (ex: array fields query) and the cursor pagination are not cached, the result of each tenant is cached separately.
+func (q *DBQuery) cacheKey(schema map[string]any, query url.Values) string {
+	if q.Cache == nil || q.isInternal || q.cacheTTL(schema) <= 0 || q.IsCursorPagination(query) {
+		return ""
+	}
+	tableName, _ := schema["tableName"].(string)
+	key := tableName + "?" + query.Encode()
+	if q.Model != nil {
+		// the models of the same table can have the different fields
+		key += "#" + fmt.Sprintf("%T", q.Model)
+	}
+	if tenant := q.tenant(); tenant != nil {
+		key += "@" + fmt.Sprintf("%v", tenant)
+	}
+	return key
+}
+
+// cacheTTL returns the cache TTL of the schema, see Model.CacheTTL
+func (q *DBQuery) cacheTTL(schema map[string]any) time.Duration {
+	ttl, _ := schema["cacheTTL"].(time.Duration)
+	return ttl
+}
+
+func init() {
+	// the types of the row values (including the included array fields and the json fields), see setCache
+	gob.Register(map[string]any{})
+	gob.Register([]any{})
+	gob.Register([]map[string]any{})
+	gob.Register(time.Time{})
+}
+
+// getCache gets the cached Find result
+func (q *DBQuery) getCache(key string) ([]map[string]any, bool) {
+	b := []byte{}
+	if err := q.Cache.Get(key, &b); err != nil {
+		return nil, false
+	}
+	rows := []map[string]any{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&rows); err != nil {
+		return nil, false
+	}
+	for i, row := range rows {
+		rows[i], _ = q.fixCacheValue(row).(map[string]any)
+	}
+	return rows, true
+}
+
+// fixCacheValue restores the empty maps and slices of the gob decoded value which are decoded as nil
+func (q *DBQuery) fixCacheValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		if val == nil {
+			return map[string]any{}
+		}
+		for k, child := range val {
+			val[k] = q.fixCacheValue(child)
+		}
+	case []map[string]any:
+		if val == nil {
+			return []map[string]any{}
+		}
+		for _, child := range val {
+			q.fixCacheValue(child)
+		}
+	case []any:
+		if val == nil {
+			return []any{}
+		}
+		for i, child := range val {
+			val[i] = q.fixCacheValue(child)
+		}
+	}
+	return v
+}
+
+// setCache caches the Find result, and registers the schema tables (joined & included) as the dependencies of the main table
+//
+// the dependencies are checked on every setCache (not remembered by the process), because the dependency keys can be evicted or cleared by Cache.Clear.
+//
+// the rows are encoded using gob (instead of json) so the cached rows have the same value types as the query result,
+// the rows with the value of unregistered gob type are not cached.
+func (q *DBQuery) setCache(schema map[string]any, key string, rows []map[string]any) {
+	b := bytes.Buffer{}
+	if err := gob.NewEncoder(&b).Encode(rows); err != nil {
+		return
+	}
+	if err := q.Cache.Set(key, b.Bytes(), q.cacheTTL(schema)); err != nil {
+		return
+	}
+	tableName, _ := schema["tableName"].(string)
+	for _, t := range q.cacheTables(schema) {
+		if t == tableName {
+			continue
+		}
+		depKey := QueryCacheDependencyPrefix + t
+		deps := []string{}
+		q.Cache.Get(depKey, &deps)
+		if !slices.Contains(deps, tableName) {
+			q.Cache.Set(depKey, append(deps, tableName), 0)
+		}
+	}
+}
+
+// InvalidateCache invalidates the cached Find results of the schema tables, see invalidateCache
+//
+// Create, Update, Patch & Delete invalidate the cache before the commit when DB is a transaction of the caller,
+// so a concurrent Find can cache the rows before the commit, call InvalidateCache after the commit to remove them.
+func (q *DBQuery) InvalidateCache(schema map[string]any) {
+	q.invalidateCache(schema, nil)
+}
+
+// invalidateCache invalidates the cached Find results of the written tables (main table & array fields tables) synchronously
+// (the same keys as Cache.Invalidate), so the next Find gets the written rows,
+// the cached results of the tables which join or include the written tables are also invalidated
+func (q *DBQuery) invalidateCache(schema map[string]any, id any) {
+	if q.Cache == nil {
+		return
+	}
+	invalidate := func(table string, keys ...string) {
+		for _, k := range keys {
+			q.Cache.Delete(table + "." + k)
+		}
+		q.Cache.DeleteWithPrefix(table + "?")
+	}
+	tableName, _ := schema["tableName"].(string)
+	tables := []string{tableName}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for _, arrayField := range arrayFields {
+		arraySchema, _ := arrayField["schema"].(map[string]any)
+		if t, _ := arraySchema["tableName"].(string); t != "" && !slices.Contains(tables, t) {
+			tables = append(tables, t)
+		}
+	}
+
+	invalidated := map[string]bool{}
+	for i, t := range tables {
+		if i == 0 && id != nil {
+			invalidate(t, fmt.Sprintf("%v", id))
+		} else {
+			invalidate(t)
+		}
+		invalidated[t] = true
+	}
+	for _, t := range tables {
+		deps := []string{}
+		q.Cache.Get(QueryCacheDependencyPrefix+t, &deps)
+		for _, dep := range deps {
+			if !invalidated[dep] {
+				invalidate(dep)
+				invalidated[dep] = true
+			}
+		}
+	}
+}
+
+// cacheTables returns the table names used by the schema, by walking the relations, the table schema and the array fields schemas
+func (q *DBQuery) cacheTables(schema map[string]any) []string {
+	tables := []string{}
+	add := func(t ...string) {
+		for _, v := range t {
+			if v != "" && !slices.Contains(tables, v) {
+				tables = append(tables, v)
+			}
+		}
+	}
+	tableName, _ := schema["tableName"].(string)
+	add(tableName)
+	if tableSchema, ok := schema["tableSchema"].(map[string]any); ok && len(tableSchema) > 0 {
+		add(q.cacheTables(tableSchema)...)
+	}
+	relations, _ := schema["relations"].(map[string]map[string]any)
+	for _, rel := range relations {
+		if t, ok := rel["tableName"].(string); ok {
+			add(t)
+		}
+		if tableSchema, ok := rel["tableSchema"].(map[string]any); ok && len(tableSchema) > 0 {
+			add(q.cacheTables(tableSchema)...)
+		}
+	}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for _, arrayField := range arrayFields {
+		if arraySchema, ok := arrayField["schema"].(map[string]any); ok {
+			add(q.cacheTables(arraySchema)...)
+		}
+	}
+	return tables
+}
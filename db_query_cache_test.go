@@ -0,0 +1,108 @@
+package grest
+
+import (
+	"net/url"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type cachedArticle struct {
+	Article
+}
+
+func (m *cachedArticle) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+func (m *cachedArticle) CacheTTL() time.Duration {
+	return time.Minute
+}
+
+func TestDBQueryCache(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	cache := &Cache{}
+	model := &cachedArticle{}
+	dq := &DBQuery{DB: db, Model: model, Cache: cache}
+	schema := model.GetSchema()
+	query := url.Values{"title": {"foo"}, QuerySelect: {"id,title"}}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow("a1", "foo"))
+	for i := 0; i < 2; i++ {
+		rows, err := dq.Find(schema, url.Values{QuerySelect: {"id,title"}, "title": {"foo"}})
+		if err != nil {
+			t.Fatalf("Error occured : [%v]", err.Error())
+		}
+		if len(rows) != 1 || rows[0]["id"] != "a1" {
+			t.Errorf("%v. Expected the article a1, got [%v]", i, rows)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected the second Find is served from the cache : [%v]", err)
+	}
+	key := dq.cacheKey(schema, query)
+	if want := "articles?%24select=id%2Ctitle&title=foo#*grest.cachedArticle"; key != want {
+		t.Errorf("Expected cache key [%v], got [%v]", want, key)
+	}
+
+	// the model without CacheTTL is not cached
+	if key := (&DBQuery{DB: db, Cache: cache}).cacheKey((&Article{}).GetSchema(), query); key != "" {
+		t.Errorf("Expected no cache key, got [%v]", key)
+	}
+
+	// writing the included table (categories) invalidates the cached articles
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "deleted_at"=`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	cq := &DBQuery{DB: db, Cache: cache}
+	if err := cq.Delete((&Category{}).GetSchema(), "c1"); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if _, ok := dq.getCache(key); ok {
+		t.Errorf("Expected the cached articles are invalidated")
+	}
+
+	// the dependencies are registered again after the cache is cleared
+	cache.Clear()
+	dq.setCache(schema, key, []map[string]any{{"id": "a1"}})
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "deleted_at"=`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := cq.Delete((&Category{}).GetSchema(), "c1"); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if _, ok := dq.getCache(key); ok {
+		t.Errorf("Expected the cached articles are invalidated after the cache is cleared")
+	}
+
+	// the cached rows have the same value types as the query result
+	includeQuery := url.Values{QuerySelect: {"id,is_active"}, QueryInclude: {"categories"}}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "is_active"}).AddRow("a1", "1").AddRow("a2", "0"))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "categories" AS "c"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id", "is_active"}).AddRow("c1", "a1", "1"))
+	rows, err := dq.Find(schema, includeQuery)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	cachedRows, err := dq.Find(schema, includeQuery)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if !reflect.DeepEqual(rows, cachedRows) {
+		t.Errorf("Expected the cached rows [%#v], got [%#v]", rows, cachedRows)
+	}
+	if categories, ok := cachedRows[0]["categories"].([]map[string]any); !ok || len(categories) != 1 || categories[0]["is_active"] != true {
+		t.Errorf("Expected the cached categories of a1, got [%#v]", cachedRows[0]["categories"])
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expected the second Find is served from the cache : [%v]", err)
+	}
+}
@@ -0,0 +1,222 @@
+package grest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// cursorAlias is the select alias prefix of the cursor columns, removed from the rows after query
+const cursorAlias = "$cursor."
+
+// IsCursorPagination return true if the cursor (keyset) pagination is requested by query params
+func (q *DBQuery) IsCursorPagination(qry ...url.Values) bool {
+	query := q.Query
+	if len(qry) > 0 {
+		query = qry[0]
+	}
+	if query.Get(QueryDisablePagination) == "true" {
+		return false
+	}
+	_, isAfter := query[QueryAfter]
+	_, isBefore := query[QueryBefore]
+	return isAfter || isBefore
+}
+
+// SetCursor specify select, where, order & limit method for cursor (keyset) pagination
+//
+// the sort columns are the effective sorts (QuerySort or model sorts) plus the primary key as tie breaker,
+// the sort columns should be not nullable because null values can not be compared.
+// the sort by QuerySearchRank is rejected with bad request error, because the rank is a select alias which can not be compared in the where clause.
+func (q *DBQuery) SetCursor(db *gorm.DB, schema map[string]any, query url.Values) (*gorm.DB, error) {
+	_, limit := q.GetPageLimit(query)
+	cursorParam := QueryAfter
+	_, isBefore := query[QueryBefore]
+	if isBefore {
+		cursorParam = QueryBefore
+	}
+	if q.isSortedBySearchRank(schema, query) {
+		return db, NewError(http.StatusBadRequest, "The "+QuerySearchRank+" sort can not be used with the "+cursorParam+" cursor.",
+			map[string]any{QuerySort: map[string]any{"cursor": cursorParam}})
+	}
+
+	selectedFields := q.getSelect(schema, query)
+	columns := []string{}
+	directions := []string{}
+	for _, srt := range q.getCursorSorts(schema, query) {
+		column := q.sortToColumnSQL(srt)
+		if column == "" {
+			continue
+		}
+		direction, _ := srt["direction"].(string)
+		isDesc := strings.ToLower(direction) == "desc"
+		if isBefore {
+			isDesc = !isDesc
+		}
+		direction = "ASC"
+		if isDesc {
+			direction = "DESC"
+		}
+		selectedFields = append(selectedFields, column+" AS "+q.Quote(cursorAlias+strconv.Itoa(len(columns))))
+		columns = append(columns, column)
+		directions = append(directions, direction)
+		db = db.Order(column + " " + direction)
+	}
+	db = db.Select(strings.Join(selectedFields, ", "))
+
+	if cursor := query.Get(cursorParam); cursor != "" {
+		values, err := q.decodeCursor(cursor, len(columns))
+		if err != nil {
+			return db, NewError(http.StatusBadRequest, "The "+cursorParam+" cursor is invalid.")
+		}
+		whereSQL, args := q.cursorToWhereSQL(columns, directions, values)
+		db = db.Where(whereSQL, args...)
+	}
+
+	if limit > 0 {
+		db = db.Limit(limit + 1)
+	}
+	return db, nil
+}
+
+// getCursorSorts return effective sorts with the primary key as tie breaker
+func (q *DBQuery) getCursorSorts(schema map[string]any, query url.Values) []map[string]any {
+	sorts := q.getSorts(schema, query)
+	if q.isGrouped(schema, query) {
+		return sorts
+	}
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	pkColumn, _ := fields[q.primaryKey(schema)]["db"].(string)
+	if pkColumn == "" {
+		return sorts
+	}
+	for _, srt := range sorts {
+		column, _ := srt["column"].(string)
+		jsonKey, _ := srt["jsonKey"].(string)
+		if column == pkColumn && jsonKey == "" {
+			return sorts
+		}
+	}
+	return append(sorts, map[string]any{"column": pkColumn, "direction": "asc"})
+}
+
+// cursorToWhereSQL convert cursor values to keyset where SQL string
+//
+// ex: (c1 > ?) OR (c1 = ? AND c2 > ?) OR (c1 = ? AND c2 = ? AND c3 < ?)
+func (q *DBQuery) cursorToWhereSQL(columns, directions []string, values []any) (string, []any) {
+	ors := []string{}
+	args := []any{}
+	for i := range columns {
+		ands := []string{}
+		for j := 0; j < i; j++ {
+			ands = append(ands, columns[j]+" = ?")
+			args = append(args, values[j])
+		}
+		operator := " > ?"
+		if directions[i] == "DESC" {
+			operator = " < ?"
+		}
+		ands = append(ands, columns[i]+operator)
+		args = append(args, values[i])
+		ors = append(ors, "("+strings.Join(ands, " AND ")+")")
+	}
+	return strings.Join(ors, " OR "), args
+}
+
+// setCursorResult trim the extra row, fill NextCursor & PrevCursor and remove the cursor columns from rows
+func (q *DBQuery) setCursorResult(query url.Values, rows []map[string]any) []map[string]any {
+	_, limit := q.GetPageLimit(query)
+	cursor := query.Get(QueryAfter)
+	_, isBefore := query[QueryBefore]
+	if isBefore {
+		cursor = query.Get(QueryBefore)
+	}
+
+	hasMore := limit > 0 && len(rows) > limit
+	if hasMore {
+		rows = rows[:limit]
+	}
+	if isBefore {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	q.NextCursor = ""
+	q.PrevCursor = ""
+	if len(rows) > 0 {
+		first := q.encodeCursor(rows[0])
+		last := q.encodeCursor(rows[len(rows)-1])
+		if isBefore {
+			if cursor != "" {
+				q.NextCursor = last
+			}
+			if hasMore {
+				q.PrevCursor = first
+			}
+		} else {
+			if hasMore {
+				q.NextCursor = last
+			}
+			if cursor != "" {
+				q.PrevCursor = first
+			}
+		}
+	}
+
+	for _, row := range rows {
+		for k := range row {
+			if strings.HasPrefix(k, cursorAlias) {
+				delete(row, k)
+			}
+		}
+	}
+	return rows
+}
+
+// encodeCursor return opaque cursor string from the cursor columns of the row
+func (q *DBQuery) encodeCursor(row map[string]any) string {
+	values := []any{}
+	for i := 0; ; i++ {
+		v, ok := row[cursorAlias+strconv.Itoa(i)]
+		if !ok {
+			break
+		}
+		values = append(values, v)
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decodeCursor return cursor values from opaque cursor string
+func (q *DBQuery) decodeCursor(cursor string, length int) ([]any, error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+	values := []any{}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	err = d.Decode(&values)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) != length {
+		return nil, NewError(http.StatusBadRequest, "cursor length mismatch")
+	}
+	for i, v := range values {
+		if n, ok := v.(json.Number); ok {
+			values[i] = n.String()
+		}
+	}
+	return values, nil
+}
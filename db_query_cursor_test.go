@@ -0,0 +1,75 @@
+package grest
+
+import (
+	"net/url"
+	"regexp"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDBQueryCursorPagination(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`"a"."title" AS "$cursor.0", "a"."id" AS "$cursor.1" FROM`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "$cursor.0", "$cursor.1"}).
+			AddRow("1", "a", "a", "1").
+			AddRow("2", "b", "b", "2").
+			AddRow("3", "c", "c", "3"))
+
+	q := url.Values{}
+	q.Add(QuerySelect, "id,title")
+	q.Add(QuerySort, "title")
+	q.Add(QueryLimit, "2")
+	q.Add(QueryAfter, "")
+	a := &Article{}
+	dq := &DBQuery{DB: db, Model: a, Schema: a.GetSchema(), Query: q}
+	rows, err := dq.Find(dq.Schema, q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got [%v]", len(rows))
+	}
+	if _, ok := rows[0]["$cursor.0"]; ok {
+		t.Errorf("Expected cursor columns to be removed, got [%v]", rows[0])
+	}
+	if dq.PrevCursor != "" {
+		t.Errorf("Expected empty prev cursor on first page, got [%v]", dq.PrevCursor)
+	}
+	values, err := dq.decodeCursor(dq.NextCursor, 2)
+	if err != nil || values[0] != "b" || values[1] != "2" {
+		t.Errorf("Expected next cursor [b 2], got [%v] [%v]", values, err)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE "a"."deleted_at" IS NULL AND (("a"."title" < $1) OR ("a"."title" = $2 AND "a"."id" < $3)) ORDER BY "a"."title" DESC,"a"."id" DESC LIMIT 3`)).
+		WithArgs("b", "b", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title", "$cursor.0", "$cursor.1"}).
+			AddRow("1", "a", "a", "1"))
+	q.Del(QueryAfter)
+	q.Add(QueryBefore, dq.NextCursor)
+	rows, err = dq.Find(dq.Schema, q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if len(rows) != 1 || dq.PrevCursor != "" || dq.NextCursor == "" {
+		t.Errorf("Expected 1 row with next cursor only, got [%v] [%v] [%v]", rows, dq.PrevCursor, dq.NextCursor)
+	}
+
+	q.Set(QueryBefore, "invalid")
+	_, err = dq.Find(dq.Schema, q)
+	if e, ok := err.(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error for invalid cursor, got [%v]", err)
+	}
+
+	// the search rank is a select alias, so it can not be used as the cursor column
+	schema := a.GetSchema()
+	schema["searchStrategy"] = PostgresFullTextSearch{}
+	rankQuery := url.Values{QuerySearch: {"title,content:foo"}, QuerySort: {"-" + QuerySearchRank}, QueryAfter: {""}}
+	_, err = dq.Find(schema, rankQuery)
+	if e, ok := err.(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error for cursor with search rank sort, got [%v]", err)
+	}
+}
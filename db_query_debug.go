@@ -0,0 +1,70 @@
+package grest
+
+import (
+	"net/http"
+	"net/url"
+
+	"gorm.io/gorm"
+)
+
+// QueryIsDebug allows DBQuery.Debug, keep it false on production because the query plan exposes the database structure
+// and EXPLAIN ANALYZE executes the query
+var QueryIsDebug = false
+
+// QueryDebug is the generated SQL of the DBQuery and its query plan, see DBQuery.Debug
+type QueryDebug struct {
+	SQL     string           `json:"sql"`               // the SQL with the args interpolated, for reading only
+	RawSQL  string           `json:"raw_sql"`           // the SQL with the placeholders, as executed by the db
+	Args    []any            `json:"args"`              // the bound args of the RawSQL
+	Explain []map[string]any `json:"explain,omitempty"` // the rows of EXPLAIN (or EXPLAIN ANALYZE) of the dialect
+}
+
+// Debug returns the generated SQL of Find with the bound args from schema and query params without executing it,
+// the query plan is also returned if isExplain is true, and the query is executed to get the actual plan if isAnalyze is true.
+//
+// forbidden error is returned if QueryIsDebug is false, and bad request error is returned if the EXPLAIN is not supported by the dialect
+func (q *DBQuery) Debug(schema map[string]any, query url.Values, isExplain, isAnalyze bool) (QueryDebug, error) {
+	debug := QueryDebug{Args: []any{}}
+	if !QueryIsDebug {
+		return debug, NewError(http.StatusForbidden, "The query debug is not enabled.")
+	}
+
+	rows := []map[string]any{}
+	db, err := q.Prepare(q.DB.Session(&gorm.Session{DryRun: true}), schema, query)
+	if err != nil {
+		return debug, q.toError(err)
+	}
+	db = q.SetSelect(db, schema, query)
+	if q.IsCursorPagination(query) {
+		db, err = q.SetCursor(db, schema, query)
+		if err != nil {
+			return debug, err
+		}
+	} else {
+		db = q.SetOrder(db, schema, query)
+		db = q.SetPagination(db, query)
+	}
+	db = db.Find(&rows)
+	if db.Error != nil {
+		return debug, q.toError(db.Error)
+	}
+	debug.RawSQL = db.Statement.SQL.String()
+	debug.Args = append(debug.Args, db.Statement.Vars...)
+	debug.SQL = q.DB.Dialector.Explain(debug.RawSQL, debug.Args...)
+	if !isExplain && !isAnalyze {
+		return debug, nil
+	}
+
+	explainSQL := q.Dialect().Explain(debug.RawSQL, isAnalyze)
+	if explainSQL == "" {
+		return debug, NewError(http.StatusBadRequest, "The query plan is not supported by the "+q.Dialect().Name()+" dialect.")
+	}
+	err = q.read(func() error {
+		debug.Explain = []map[string]any{}
+		return q.DB.Raw(explainSQL, debug.Args...).Scan(&debug.Explain).Error
+	})
+	if err != nil {
+		return debug, q.toError(err)
+	}
+	return debug, nil
+}
@@ -0,0 +1,63 @@
+package grest
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDBQueryDebug(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	schema := (&Article{}).GetSchema()
+	query := url.Values{"title": {"foo"}, QuerySelect: {"id,title"}}
+
+	_, err = dq.Debug(schema, query, true, false)
+	if e, ok := err.(*Error); !ok || e.Code != 403 {
+		t.Errorf("Expected 403 error when debug is not enabled, got [%v]", err)
+	}
+
+	QueryIsDebug = true
+	defer func() { QueryIsDebug = false }()
+	mock.ExpectQuery(regexp.QuoteMeta(`EXPLAIN ANALYZE SELECT "a"."id" AS "id", "a"."title" AS "title" FROM "articles" AS "a"`)).
+		WithArgs("foo").
+		WillReturnRows(sqlmock.NewRows([]string{"QUERY PLAN"}).AddRow("Seq Scan on articles a"))
+	debug, err := dq.Debug(schema, query, true, true)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+	if !strings.Contains(debug.RawSQL, `"a"."title"=$1`) || len(debug.Args) != 1 || debug.Args[0] != "foo" {
+		t.Errorf("Expected raw SQL with bound args, got [%v] %v", debug.RawSQL, debug.Args)
+	}
+	if !strings.Contains(debug.SQL, `"a"."title"='foo'`) {
+		t.Errorf("Expected SQL with interpolated args, got [%v]", debug.SQL)
+	}
+	if len(debug.Explain) != 1 || debug.Explain[0]["QUERY PLAN"] != "Seq Scan on articles a" {
+		t.Errorf("Expected the query plan, got [%v]", debug.Explain)
+	}
+
+	// ToSQL selects all fields (it is used for the sub queries) without mutating the schema, so the selected fields are still used by the next query
+	if sql := dq.ToSQL(schema, query); !strings.Contains(sql, `SELECT "a"."id" AS "id", "a"."title" AS "title", "a"."content" AS "content"`) {
+		t.Errorf("Expected all fields are selected in:\n%v", sql)
+	}
+	if _, ok := schema["is_skip_query_select"]; ok {
+		t.Errorf("Expected the schema is not mutated")
+	}
+	debug, err = dq.Debug(schema, url.Values{QuerySelect: {"title,total_review"}}, false, false)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if !strings.Contains(debug.RawSQL, `SELECT "a"."title" AS "title", coalesce(tr.total_review,0) AS "total_review" FROM`) ||
+		!strings.Contains(debug.RawSQL, `SELECT "r"."article_id" AS "id", count(r.article_id) AS "total_review" FROM "reviews"`) {
+		t.Errorf("Expected the selected fields without affecting the sub query in:\n%v", debug.RawSQL)
+	}
+}
@@ -0,0 +1,303 @@
+package grest
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// supported export formats, see Exporter
+var (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+	ExportXLSX   = "xlsx"
+)
+
+// Exporter writes the rows of DBQuery.Find (WriteAll) or DBQuery.FindEach (Write) as CSV, NDJSON or XLSX
+//
+// the nested json is flattened using JSON.ToFlat, the columns follow the fieldOrder of the Schema (except the hidden fields),
+// the flattened keys of the json field (ex: detail.color) are placed in the position of the json field.
+// the column headers use the title struct tag (or the field key if it is not setted), translated by the Translator if the Lang is setted.
+//
+//	exp := &grest.Exporter{Writer: w, Format: grest.ExportXLSX, Schema: schema, Translator: translator, Lang: lang}
+//	err := q.FindEach(schema, query, exp.Write)
+//	if err == nil {
+//		err = exp.Close()
+//	}
+type Exporter struct {
+	Writer     io.Writer
+	Format     string
+	Schema     map[string]any
+	Columns    []string // the field keys to be exported, the fieldOrder of the Schema is used if not setted
+	Translator *Translator
+	Lang       string
+
+	// write the CSV value which starts with =, +, -, @, tab or carriage return as is (except the number),
+	// by default it is prefixed with ' so the spreadsheet does not evaluate it as a formula (formula injection),
+	// the XLSX value is always written as is because the string is written as inline string which is not evaluated
+	IsAllowFormula bool
+
+	columns []string
+	csv     *csv.Writer
+	zip     *zip.Writer
+	sheet   io.Writer
+}
+
+// ContentType returns the Content-Type HTTP header of the export format
+func (e *Exporter) ContentType() string {
+	switch e.Format {
+	case ExportCSV:
+		return "text/csv; charset=utf-8"
+	case ExportNDJSON:
+		return "application/x-ndjson"
+	case ExportXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "application/octet-stream"
+}
+
+// WriteAll writes all rows and closes the Exporter
+func (e *Exporter) WriteAll(rows []map[string]any) error {
+	for _, row := range rows {
+		if err := e.Write(row); err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
+
+// Write writes the row, the header is written on the first row
+func (e *Exporter) Write(row map[string]any) error {
+	flat, _ := NewJSON(row).ToFlat().Data.(map[string]any)
+	if e.columns == nil {
+		if err := e.writeHeader(flat); err != nil {
+			return err
+		}
+	}
+	switch e.Format {
+	case ExportCSV:
+		record := make([]string, len(e.columns))
+		for i, c := range e.columns {
+			record[i] = e.escapeFormula(e.formatValue(flat[c]))
+		}
+		return e.toError(e.csv.Write(record))
+	case ExportNDJSON:
+		data := make(map[string]any, len(e.columns))
+		for _, c := range e.columns {
+			data[c] = flat[c]
+		}
+		b, err := json.Marshal(data)
+		if err != nil {
+			return e.toError(err)
+		}
+		_, err = e.Writer.Write(append(b, '\n'))
+		return e.toError(err)
+	case ExportXLSX:
+		cells := make([]any, len(e.columns))
+		for i, c := range e.columns {
+			cells[i] = flat[c]
+		}
+		return e.writeXLSXRow(cells)
+	}
+	return nil
+}
+
+// Close writes the header if there is no row, then flushes the buffered data, the Writer is not closed
+func (e *Exporter) Close() error {
+	if e.columns == nil {
+		if err := e.writeHeader(map[string]any{}); err != nil {
+			return err
+		}
+	}
+	switch e.Format {
+	case ExportCSV:
+		e.csv.Flush()
+		return e.toError(e.csv.Error())
+	case ExportXLSX:
+		if _, err := io.WriteString(e.sheet, `</sheetData></worksheet>`); err != nil {
+			return e.toError(err)
+		}
+		return e.toError(e.zip.Close())
+	}
+	return nil
+}
+
+// writeHeader sets the columns based on the first flattened row and writes the header
+func (e *Exporter) writeHeader(flat map[string]any) error {
+	fields, _ := e.Schema["fields"].(map[string]map[string]any)
+	keys := e.Columns
+	if len(keys) == 0 {
+		fieldOrder, _ := e.Schema["fieldOrder"].([]string)
+		for _, k := range fieldOrder {
+			if isHide, _ := fields[k]["isHide"].(bool); !isHide {
+				keys = append(keys, k)
+			}
+		}
+	}
+	e.columns = []string{}
+	titles := []string{}
+	for _, k := range keys {
+		subKeys := []string{}
+		if _, ok := flat[k]; !ok {
+			for fk := range flat {
+				if strings.HasPrefix(fk, k+".") {
+					subKeys = append(subKeys, fk)
+				}
+			}
+			slices.Sort(subKeys)
+		}
+		title, _ := fields[k]["title"].(string)
+		if title == "" {
+			title = k
+		}
+		if e.Translator != nil && e.Lang != "" {
+			title = e.Translator.Trans(e.Lang, title)
+		}
+		if len(subKeys) == 0 {
+			e.columns = append(e.columns, k)
+			titles = append(titles, title)
+		}
+		for _, sk := range subKeys {
+			e.columns = append(e.columns, sk)
+			titles = append(titles, title+strings.TrimPrefix(sk, k))
+		}
+	}
+
+	switch e.Format {
+	case ExportCSV:
+		e.csv = csv.NewWriter(e.Writer)
+		return e.toError(e.csv.Write(titles))
+	case ExportNDJSON:
+		return nil
+	case ExportXLSX:
+		if err := e.startXLSX(); err != nil {
+			return e.toError(err)
+		}
+		cells := make([]any, len(titles))
+		for i, t := range titles {
+			cells[i] = t
+		}
+		return e.writeXLSXRow(cells)
+	}
+	return NewError(http.StatusBadRequest, fmt.Sprintf("The export format %s is not supported.", e.Format))
+}
+
+// startXLSX writes the minimal parts of the workbook, then starts the worksheet which is streamed row by row
+func (e *Exporter) startXLSX() error {
+	e.zip = zip.NewWriter(e.Writer)
+	parts := [][2]string{
+		{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` +
+			`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, p := range parts {
+		w, err := e.zip.Create(p[0])
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, p[1]); err != nil {
+			return err
+		}
+	}
+	sheet, err := e.zip.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	e.sheet = sheet
+	_, err = io.WriteString(e.sheet, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	return err
+}
+
+// writeXLSXRow writes the worksheet row, number and boolean are written as is, others are written as inline string
+func (e *Exporter) writeXLSXRow(cells []any) error {
+	row := strings.Builder{}
+	row.WriteString("<row>")
+	for _, v := range cells {
+		switch val := v.(type) {
+		case nil:
+			row.WriteString("<c/>")
+		case bool:
+			b := "0"
+			if val {
+				b = "1"
+			}
+			row.WriteString(`<c t="b"><v>` + b + `</v></c>`)
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+			row.WriteString(`<c><v>` + e.formatValue(val) + `</v></c>`)
+		default:
+			row.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+			xml.EscapeText(&row, []byte(e.formatValue(val)))
+			row.WriteString(`</t></is></c>`)
+		}
+	}
+	row.WriteString("</row>")
+	_, err := io.WriteString(e.sheet, row.String())
+	return e.toError(err)
+}
+
+// formatValue returns the string of the flattened value, the array (ex: included array fields) is written as json
+func (e *Exporter) formatValue(v any) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(val), 'f', -1, 32)
+	case time.Time:
+		return val.Format(time.RFC3339)
+	case []any, map[string]any:
+		b, _ := json.Marshal(val)
+		return string(b)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// escapeFormula prefixes the CSV value which may be evaluated as a formula with ', unless IsAllowFormula or the value is a number (ex: -12.50)
+func (e *Exporter) escapeFormula(s string) string {
+	if e.IsAllowFormula || s == "" || !strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return s
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return s
+	}
+	return "'" + s
+}
+
+// toError wraps the writer error as *Error
+func (e *Exporter) toError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return NewError(http.StatusInternalServerError, err.Error())
+}
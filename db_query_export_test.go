@@ -0,0 +1,123 @@
+package grest
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExporter(t *testing.T) {
+	schema := (&Article{}).GetSchema()
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	fields["title"]["title"] = "Title"
+	columns := []string{"id", "title", "detail", "is_active", "total_review"}
+	rows := []map[string]any{
+		{"id": "a1", "title": "Foo, Bar", "detail": map[string]any{"color": "red", "size": map[string]any{"w": 2}}, "is_active": true, "total_review": 4.5},
+		{"id": "a2", "title": "<Baz>", "detail": nil, "is_active": false, "total_review": 10},
+	}
+	translator := &Translator{}
+	translator.AddTranslation("id-ID", map[string]string{"Title": "Judul"})
+
+	buf := &bytes.Buffer{}
+	exp := &Exporter{Writer: buf, Format: ExportCSV, Schema: schema, Columns: columns, Translator: translator, Lang: "id-ID"}
+	if err := exp.WriteAll(rows); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	expected := "id,Judul,detail.color,detail.size.w,is_active,total_review\n" +
+		"a1,\"Foo, Bar\",red,2,true,4.5\n" +
+		"a2,<Baz>,,,false,10\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%v\nGot:\n%v", expected, buf.String())
+	}
+
+	buf.Reset()
+	exp = &Exporter{Writer: buf, Format: ExportNDJSON, Schema: schema, Columns: columns}
+	if err := exp.WriteAll(rows); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 || lines[0] != `{"detail.color":"red","detail.size.w":2,"id":"a1","is_active":true,"title":"Foo, Bar","total_review":4.5}` {
+		t.Errorf("Expected 2 flattened json lines, got:\n%v", buf.String())
+	}
+
+	buf.Reset()
+	exp = &Exporter{Writer: buf, Format: ExportXLSX, Schema: schema, Columns: columns}
+	if err := exp.WriteAll(rows); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Expected valid xlsx, got [%v]", err)
+	}
+	sheet := ""
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			b, _ := io.ReadAll(r)
+			sheet = string(b)
+		}
+	}
+	for _, expected := range []string{
+		`<t xml:space="preserve">Title</t>`,
+		`<t xml:space="preserve">&lt;Baz&gt;</t>`,
+		`<c t="b"><v>1</v></c><c><v>4.5</v></c></row>`,
+		`</sheetData></worksheet>`,
+	} {
+		if !strings.Contains(sheet, expected) {
+			t.Errorf("Expected [%v] in:\n%v", expected, sheet)
+		}
+	}
+
+	// the columns follow the fieldOrder except the hidden fields, the header is written without rows
+	buf.Reset()
+	exp = &Exporter{Writer: buf, Format: ExportCSV, Schema: schema}
+	if err := exp.Close(); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if header := strings.TrimSpace(buf.String()); !strings.HasPrefix(header, "id,Title,content,author.id") || strings.Contains(header, "is_hidden") {
+		t.Errorf("Expected header follows the field order, got [%v]", header)
+	}
+
+	// the CSV string which may be evaluated as a formula is prefixed with ', unless IsAllowFormula or it is a number
+	formulaRows := []map[string]any{{"id": "a3", "title": "=HYPERLINK(\"http://x\")", "detail": nil, "is_active": true, "total_review": "-12.50"}}
+	buf.Reset()
+	exp = &Exporter{Writer: buf, Format: ExportCSV, Schema: schema, Columns: columns}
+	if err := exp.WriteAll(formulaRows); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if !strings.Contains(buf.String(), `a3,"'=HYPERLINK(""http://x"")",,true,-12.50`) {
+		t.Errorf("Expected the formula is escaped, got:\n%v", buf.String())
+	}
+	buf.Reset()
+	exp = &Exporter{Writer: buf, Format: ExportCSV, Schema: schema, Columns: columns, IsAllowFormula: true}
+	if err := exp.WriteAll(formulaRows); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if !strings.Contains(buf.String(), `a3,"=HYPERLINK(""http://x"")",,true,-12.50`) {
+		t.Errorf("Expected the formula is written as is, got:\n%v", buf.String())
+	}
+
+	// the XLSX inline string is not evaluated as a formula, so it is written as is
+	buf.Reset()
+	exp = &Exporter{Writer: buf, Format: ExportXLSX, Schema: schema, Columns: columns}
+	if err := exp.WriteAll([]map[string]any{{"id": "@SUM(1)", "title": "-12.50"}}); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	zr, _ = zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			r, _ := f.Open()
+			b, _ := io.ReadAll(r)
+			if !strings.Contains(string(b), `<t xml:space="preserve">@SUM(1)</t>`) || !strings.Contains(string(b), `<t xml:space="preserve">-12.50</t>`) {
+				t.Errorf("Expected the inline strings are written as is in:\n%v", string(b))
+			}
+		}
+	}
+
+	exp = &Exporter{Writer: buf, Format: "pdf", Schema: schema}
+	if e, ok := exp.Close().(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error for unsupported format, got [%v]", e)
+	}
+}
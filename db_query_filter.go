@@ -0,0 +1,363 @@
+package grest
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FilterNode is the node of the parsed QueryFilter expression
+//
+// the logical node has Op ("and", "or" or "not") with Children,
+// the comparison node has empty Op with Field, Operator (eq, ne, gt, ge, lt, le, like or in) and Value (string, []string or nil for null)
+type FilterNode struct {
+	Op       string
+	Children []*FilterNode
+	Field    string
+	Operator string
+	Value    any
+	Pos      int
+}
+
+// filterOperators maps the QueryFilter comparison operators to query params operators
+var filterOperators = map[string]*string{
+	"eq":   &QueryOptEqual,
+	"ne":   &QueryOptNotEqual,
+	"gt":   &QueryOptGreaterThan,
+	"ge":   &QueryOptGreaterThanOrEqual,
+	"lt":   &QueryOptLowerThan,
+	"le":   &QueryOptLowerThanOrEqual,
+	"like": &QueryOptLike,
+	"in":   &QueryOptIn,
+}
+
+const (
+	filterTokenEOF = iota
+	filterTokenIdent
+	filterTokenString
+	filterTokenNumber
+	filterTokenLParen
+	filterTokenRParen
+	filterTokenComma
+)
+
+type filterToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+// filterParser is a recursive descent parser of the QueryFilter expression :
+//
+//	expr       = and { "or" and }
+//	and        = unary { "and" unary }
+//	unary      = "not" unary | "(" expr ")" | comparison
+//	comparison = field operator value | field "in" "(" value { "," value } ")"
+//	value      = 'string' | number | true | false | null
+type filterParser struct {
+	tokens []filterToken
+	idx    int
+}
+
+// ParseFilter parse the QueryFilter expression to FilterNode, the syntax error is returned as bad request *Error with the position (1-based)
+//
+// ex: (status eq 'open' or priority gt 3) and not (owner.id in (1,2))
+func ParseFilter(expr string) (*FilterNode, error) {
+	tokens, err := filterTokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != filterTokenEOF {
+		return nil, filterSyntaxError(tok.pos, "unexpected "+strconv.Quote(tok.text))
+	}
+	return node, nil
+}
+
+// filterSyntaxError return bad request *Error of the QueryFilter syntax error at pos (0-based)
+func filterSyntaxError(pos int, msg string) *Error {
+	return NewError(http.StatusBadRequest, "The "+QueryFilter+" is invalid at position "+strconv.Itoa(pos+1)+": "+msg+".", map[string]any{
+		QueryFilter: map[string]any{"position": pos + 1, "message": msg},
+	})
+}
+
+// filterTokenize split the QueryFilter expression to tokens
+func filterTokenize(expr string) ([]filterToken, error) {
+	tokens := []filterToken{}
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, filterToken{kind: filterTokenLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, filterToken{kind: filterTokenRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, filterToken{kind: filterTokenComma, text: ",", pos: i})
+			i++
+		case c == '\'':
+			str := strings.Builder{}
+			start := i
+			i++
+			for {
+				if i >= len(expr) {
+					return nil, filterSyntaxError(start, "unterminated string")
+				}
+				if expr[i] == '\'' {
+					// '' is escaped single quote
+					if i+1 < len(expr) && expr[i+1] == '\'' {
+						str.WriteByte('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				str.WriteByte(expr[i])
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenString, text: str.String(), pos: start})
+		case c == '-' || c == '+' || (c >= '0' && c <= '9'):
+			start := i
+			i++
+			for i < len(expr) && (expr[i] == '.' || (expr[i] >= '0' && expr[i] <= '9')) {
+				i++
+			}
+			if _, err := strconv.ParseFloat(expr[start:i], 64); err != nil {
+				return nil, filterSyntaxError(start, "invalid number "+strconv.Quote(expr[start:i]))
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenNumber, text: expr[start:i], pos: start})
+		case isFilterIdentChar(c):
+			start := i
+			for i < len(expr) && isFilterIdentChar(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, filterToken{kind: filterTokenIdent, text: expr[start:i], pos: start})
+		default:
+			return nil, filterSyntaxError(i, "unexpected character "+strconv.Quote(string(c)))
+		}
+	}
+	tokens = append(tokens, filterToken{kind: filterTokenEOF, text: "end of expression", pos: len(expr)})
+	return tokens, nil
+}
+
+// isFilterIdentChar return true if c is a part of field (dot notation, with optional cast) or keyword
+func isFilterIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '$' || c == ':' || c == '*' ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *filterParser) peek() filterToken {
+	return p.tokens[p.idx]
+}
+
+func (p *filterParser) next() filterToken {
+	tok := p.tokens[p.idx]
+	if tok.kind != filterTokenEOF {
+		p.idx++
+	}
+	return tok
+}
+
+// isKeyword return true if the next token is the keyword kw (case insensitive)
+func (p *filterParser) isKeyword(kw string) bool {
+	tok := p.peek()
+	return tok.kind == filterTokenIdent && strings.EqualFold(tok.text, kw)
+}
+
+func (p *filterParser) parseOr() (*FilterNode, error) {
+	pos := p.peek().pos
+	node, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*FilterNode{node}
+	for p.isKeyword("or") {
+		p.next()
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &FilterNode{Op: "or", Children: children, Pos: pos}, nil
+}
+
+func (p *filterParser) parseAnd() (*FilterNode, error) {
+	pos := p.peek().pos
+	node, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	children := []*FilterNode{node}
+	for p.isKeyword("and") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, node)
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &FilterNode{Op: "and", Children: children, Pos: pos}, nil
+}
+
+func (p *filterParser) parseUnary() (*FilterNode, error) {
+	tok := p.peek()
+	if p.isKeyword("not") {
+		p.next()
+		node, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &FilterNode{Op: "not", Children: []*FilterNode{node}, Pos: tok.pos}, nil
+	}
+	if tok.kind == filterTokenLParen {
+		p.next()
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.kind != filterTokenRParen {
+			return nil, filterSyntaxError(tok.pos, "expected \")\" but found "+strconv.Quote(tok.text))
+		}
+		return node, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (*FilterNode, error) {
+	field := p.next()
+	if field.kind != filterTokenIdent || p.isKeywordText(field.text) {
+		return nil, filterSyntaxError(field.pos, "expected field but found "+strconv.Quote(field.text))
+	}
+	opt := p.next()
+	operator := strings.ToLower(opt.text)
+	if _, ok := filterOperators[operator]; opt.kind != filterTokenIdent || !ok {
+		return nil, filterSyntaxError(opt.pos, "expected operator (eq, ne, gt, ge, lt, le, like or in) but found "+strconv.Quote(opt.text))
+	}
+	node := &FilterNode{Field: field.text, Operator: operator, Pos: field.pos}
+	if operator != "in" {
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		node.Value = val
+		return node, nil
+	}
+
+	if tok := p.next(); tok.kind != filterTokenLParen {
+		return nil, filterSyntaxError(tok.pos, "expected \"(\" but found "+strconv.Quote(tok.text))
+	}
+	values := []string{}
+	for {
+		val, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		str, _ := val.(string)
+		values = append(values, str)
+		tok := p.next()
+		if tok.kind == filterTokenRParen {
+			break
+		}
+		if tok.kind != filterTokenComma {
+			return nil, filterSyntaxError(tok.pos, "expected \",\" or \")\" but found "+strconv.Quote(tok.text))
+		}
+	}
+	node.Value = values
+	return node, nil
+}
+
+// parseValue return string value of the literal, or nil for null
+func (p *filterParser) parseValue() (any, error) {
+	tok := p.next()
+	switch tok.kind {
+	case filterTokenString, filterTokenNumber:
+		return tok.text, nil
+	case filterTokenIdent:
+		switch strings.ToLower(tok.text) {
+		case "true", "false":
+			return strings.ToLower(tok.text), nil
+		case "null":
+			return nil, nil
+		}
+	}
+	return nil, filterSyntaxError(tok.pos, "expected value but found "+strconv.Quote(tok.text))
+}
+
+// isKeywordText return true if text is a logical keyword
+func (p *filterParser) isKeywordText(text string) bool {
+	switch strings.ToLower(text) {
+	case "and", "or", "not":
+		return true
+	}
+	return false
+}
+
+// filterToWhereSQL compile the FilterNode to where SQL string and args, the comparison is converted to the schema condition
+// (same as query params filter) and compiled by condToWhereSQL
+func (q *DBQuery) filterToWhereSQL(node *FilterNode, fields map[string]map[string]any, arrayFields map[string]map[string]any) (string, []any, error) {
+	switch node.Op {
+	case "and", "or":
+		parts := []string{}
+		args := []any{}
+		for _, child := range node.Children {
+			whereSQL, childArgs, err := q.filterToWhereSQL(child, fields, arrayFields)
+			if err != nil {
+				return "", nil, err
+			}
+			parts = append(parts, whereSQL)
+			args = append(args, childArgs...)
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(node.Op)+" ") + ")", args, nil
+	case "not":
+		whereSQL, args, err := q.filterToWhereSQL(node.Children[0], fields, arrayFields)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + whereSQL + ")", args, nil
+	}
+
+	val := ""
+	switch v := node.Value.(type) {
+	case nil:
+		val = "null"
+	case string:
+		val = v
+	case []string:
+		val = strings.Join(v, ",")
+	}
+	cond := q.qsToCond(node.Field+"."+*filterOperators[node.Operator], val, fields, arrayFields)
+	if err := q.condError(cond); err != nil {
+		return "", nil, err
+	}
+	if cond["column1"] == nil && cond["subQuery"] == nil {
+		return "", nil, filterSyntaxError(node.Pos, "unknown field "+strconv.Quote(node.Field))
+	}
+	if cond["column1"] != nil {
+		// use the parsed value as is, the string literal is not url encoded and can not refer to another field
+		cond["value"] = node.Value
+		delete(cond, "column2")
+		if q.IsStrict {
+			cond["value"] = q.coerceCondValue(cond)
+		}
+	}
+	whereSQL, arg := q.condToWhereSQL(cond)
+	if strings.Contains(whereSQL, "?") {
+		return whereSQL, []any{arg}, nil
+	}
+	return whereSQL, []any{}, nil
+}
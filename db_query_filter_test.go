@@ -0,0 +1,77 @@
+package grest
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestParseFilter(t *testing.T) {
+	node, err := ParseFilter(`(title eq 'it''s' or total_review gt 3) and not (author.id in ('a','b'))`)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if node.Op != "and" || len(node.Children) != 2 || node.Children[0].Op != "or" || node.Children[1].Op != "not" {
+		t.Fatalf("Unexpected AST [%+v]", node)
+	}
+	if cmp := node.Children[0].Children[0]; cmp.Field != "title" || cmp.Operator != "eq" || cmp.Value != "it's" {
+		t.Errorf("Unexpected comparison [%+v]", cmp)
+	}
+
+	tests := map[string]int{
+		`title eq`:                      9,
+		`(title eq 'a'`:                 14,
+		`title foo 'a'`:                 7,
+		`title eq 'a' and`:              17,
+		`title eq 'a`:                   10,
+		`title in ('a' 'b')`:            15,
+		`title eq 'a' or # eq 1`:        17,
+		`title eq 'a') and id eq 'b'`:   13,
+		`and eq 1`:                      1,
+		`author.id in ('a',) or x eq 1`: 19,
+	}
+	for expr, pos := range tests {
+		_, err := ParseFilter(expr)
+		e, ok := err.(*Error)
+		if !ok || e.Code != 400 {
+			t.Errorf("Expected 400 error for [%v], got [%v]", expr, err)
+			continue
+		}
+		detail, _ := e.Detail.(map[string]any)
+		if filterDetail, _ := detail[QueryFilter].(map[string]any); filterDetail["position"] != pos {
+			t.Errorf("Expected position [%v] for [%v], got [%v]", pos, expr, e.Message)
+		}
+	}
+}
+
+func TestDBQueryFilter(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	q := url.Values{}
+	q.Add(QueryFilter, `(title eq 'foo' or total_review gt 3) and not (author.id in ('a','b') or deleted_at ne null)`)
+	mock.ExpectQuery(regexp.QuoteMeta(`AND ((("a"."title"=$1 OR coalesce(tr.total_review,0)>$2) AND NOT (("a"."author_id" IN ($3,$4) OR "a"."deleted_at" IS NOT NULL))))`)).
+		WithArgs("foo", "3", "a", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	_, err = dq.Find((&Article{}).GetSchema(), q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	q.Set(QueryFilter, `categories.0.code eq 'news'`)
+	sql := dq.ToSQL((&Article{}).GetSchema(), q)
+	if !strings.Contains(sql, `EXISTS (SELECT 1 FROM "categories" AS "c"`) || !strings.Contains(sql, `"c"."code"='news'`) {
+		t.Errorf("Expected exists sub query, got [%v]", sql)
+	}
+
+	q.Set(QueryFilter, `unknown eq 1`)
+	_, err = dq.Find((&Article{}).GetSchema(), q)
+	if e, ok := err.(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error, got [%v]", err)
+	}
+}
@@ -0,0 +1,125 @@
+package grest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// includePaths returns the included array field keys of the schema and the nested includes of each array field key
+//
+//	$include=orders.items.product_options,phones > keys: [orders, phones], nested: {orders: [items.product_options]}
+func (q *DBQuery) includePaths(schema map[string]any, include string) ([]string, map[string][]string) {
+	keys := []string{}
+	nested := map[string][]string{}
+	if include == "" {
+		return keys, nested
+	}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	addKey := func(k string) {
+		if _, ok := nested[k]; !ok {
+			keys = append(keys, k)
+			nested[k] = []string{}
+		}
+	}
+	for _, path := range strings.Split(include, ",") {
+		if path == "all" {
+			arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
+			for _, k := range arrayFieldOrder {
+				addKey(k)
+			}
+			continue
+		}
+		k, child := q.splitIncludePath(arrayFields, path)
+		if k == "" {
+			continue
+		}
+		addKey(k)
+		if child != "" {
+			nested[k] = append(nested[k], child)
+		}
+	}
+	return keys, nested
+}
+
+// splitIncludePath returns the array field key of the include path and the rest of the path,
+// the longest array field key is used since the array field key can contain dot (ex: json:"detail.items")
+func (q *DBQuery) splitIncludePath(arrayFields map[string]map[string]any, path string) (string, string) {
+	key, child := "", ""
+	for k := range arrayFields {
+		if len(k) <= len(key) {
+			continue
+		}
+		if path == k {
+			key, child = k, ""
+		} else if c, ok := strings.CutPrefix(path, k+"."); ok {
+			key, child = k, c
+		}
+	}
+	return key, child
+}
+
+// includeDepth returns the max depth of the include paths, the unknown array field is not counted
+func (q *DBQuery) includeDepth(schema map[string]any, include string) int {
+	depth := 0
+	keys, nested := q.includePaths(schema, include)
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for _, k := range keys {
+		d := 1
+		if arraySchema, ok := arrayFields[k]["schema"].(map[string]any); ok && len(nested[k]) > 0 {
+			d += q.includeDepth(arraySchema, strings.Join(nested[k], ","))
+		}
+		if d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+// checkIncludeDepth rejects the nested includes deeper than maxDepth, including the scoped includes (ex: orders.*.$include=items)
+func (q *DBQuery) checkIncludeDepth(schema map[string]any, query url.Values, maxDepth int) error {
+	include := q.scopedInclude(schema, query)
+	if q.includeDepth(schema, include) > maxDepth {
+		return NewError(http.StatusBadRequest, fmt.Sprintf("The %s depth must not be greater than %d.", QueryInclude, maxDepth),
+			map[string]any{QueryInclude: map[string]any{"max": maxDepth}})
+	}
+	return nil
+}
+
+// scopedInclude returns the QueryInclude merged with the scoped includes as the dotted paths
+//
+//	$include=orders&orders.*.$include=items > orders,orders.items
+func (q *DBQuery) scopedInclude(schema map[string]any, query url.Values) string {
+	includes := []string{}
+	if include := query.Get(QueryInclude); include != "" {
+		includes = append(includes, include)
+	}
+	for key, vals := range query {
+		prefix, ok := strings.CutSuffix(key, "."+QueryInclude)
+		if !ok || !strings.Contains(prefix, ".*") {
+			continue
+		}
+		prefix = strings.ReplaceAll(prefix+".", ".*.", ".")
+		for _, v := range vals {
+			for _, path := range strings.Split(v, ",") {
+				includes = append(includes, prefix+path)
+			}
+		}
+	}
+	return strings.Join(includes, ",")
+}
+
+// isScopedQueryParam returns true if the key is the query params of the array field rows (ex: orders.*.$sort),
+// instead of the filter of the parent rows
+func (q *DBQuery) isScopedQueryParam(key string) bool {
+	i := strings.LastIndex(key, ".*.")
+	if i < 0 {
+		return false
+	}
+	switch key[i+3:] {
+	case QueryInclude, QueryExclude, QuerySelect, QuerySort, QueryLimit, QueryOffset, QueryPage, QueryDisablePagination:
+		return true
+	}
+	return false
+}
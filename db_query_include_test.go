@@ -0,0 +1,134 @@
+package grest
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type OrderItemOption struct {
+	Model
+	ID          NullUUID   `json:"id"            db:"oio.id"`
+	OrderItemID NullUUID   `json:"order_item.id" db:"oio.order_item_id,hide"`
+	Name        NullString `json:"name"          db:"oio.name"`
+}
+
+func (OrderItemOption) TableName() string {
+	return "order_item_options"
+}
+
+func (OrderItemOption) TableAliasName() string {
+	return "oio"
+}
+
+func (m *OrderItemOption) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+func (m *OrderItemOption) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+// orderSchemaWithOptions returns the order schema with the options array field on the items
+func orderSchemaWithOptions() map[string]any {
+	schema := (&Order{}).GetSchema()
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	itemSchema, _ := arrayFields["items"]["schema"].(map[string]any)
+	itemSchema["arrayFields"] = map[string]map[string]any{
+		"options": {"schema": (&OrderItemOption{}).GetSchema(), "filter": "order_item.id={id}"},
+	}
+	itemSchema["arrayFieldOrder"] = []string{"options"}
+	return schema
+}
+
+func TestDBQueryNestedInclude(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	schema := orderSchemaWithOptions()
+	dq := &DBQuery{DB: db}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "orders" AS "o"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "number"}).
+			AddRow("o1", "SO-001").
+			AddRow("o2", "SO-002"))
+	mock.ExpectQuery(`FROM "order_items" AS "oi" WHERE .*"oi"."qty">\$.* ORDER BY "oi"."product" DESC`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order.id"}).
+			AddRow("i1", "o1").
+			AddRow("i2", "o1"))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "order_item_options" AS "oio" WHERE "oio"."order_item_id" IN ($1,$2)`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "order_item.id"}).
+			AddRow("x1", "gift wrap", "i2"))
+
+	rows, err := dq.Find(schema, url.Values{
+		QueryInclude:           {"items.options"},
+		"items.*.qty.$gt":      {"1"},
+		"items.*." + QuerySort: {"-product"},
+	})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+	result := map[string]string{}
+	for _, row := range rows {
+		items, _ := row["items"].([]map[string]any)
+		for _, item := range items {
+			options, _ := item["options"].([]map[string]any)
+			result[fmt.Sprintf("%v.%v", row["id"], item["id"])] = fmt.Sprint(len(options))
+		}
+	}
+	if fmt.Sprint(result) != "map[o1.i1:0 o1.i2:1]" {
+		t.Errorf("Expected nested items and options, got [%v]", result)
+	}
+}
+
+func TestDBQueryIncludeDepth(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	schema := orderSchemaWithOptions()
+	dq := &DBQuery{DB: db}
+
+	testCases := []struct {
+		include string
+		depth   int
+	}{
+		{"", 0},
+		{"items", 1},
+		{"all", 1},
+		{"items.options", 2},
+		{"items.all", 2},
+		{"items,items.options,unknown.options", 2},
+	}
+	for _, tc := range testCases {
+		if depth := dq.includeDepth(schema, tc.include); depth != tc.depth {
+			t.Errorf("Expected depth of [%v] is [%v], got [%v]", tc.include, tc.depth, depth)
+		}
+	}
+
+	dq.Limits = &QueryLimits{MaxIncludeDepth: 1, IsAllowDisablePagination: true}
+	for _, query := range []url.Values{
+		{QueryInclude: {"items.options"}},
+		{QueryInclude: {"items"}, "items.*." + QueryInclude: {"options"}},
+	} {
+		_, err = dq.Find(schema, query)
+		if e, ok := err.(*Error); !ok || e.Code != 400 {
+			t.Errorf("Expected 400 error for %v, got [%v]", query, err)
+		}
+	}
+
+	// the scoped query params of the array field rows are not the filters of the parent rows
+	sql := dq.ToSQL(schema, url.Values{"items.*." + QuerySort: {"-product"}, "items.*.options.*." + QuerySelect: {"name"}})
+	if strings.Contains(sql, "EXISTS") {
+		t.Errorf("Expected no sub query in:\n%v", sql)
+	}
+}
@@ -0,0 +1,176 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+)
+
+// joinedRelations returns the relation aliases to be joined, the left join is pruned if it is not referenced by the query.
+//
+// the relation is always joined if it is not a left join (ex: inner join filters the rows) or the isRequired is true (ex: left join that multiply the rows),
+// the left join is joined if the alias is referenced by the selected fields, filters, sorts, groups or the conditions of another joined relation.
+func (q *DBQuery) joinedRelations(schema map[string]any, query url.Values) map[string]bool {
+	relations, _ := schema["relations"].(map[string]map[string]any)
+	references := q.joinReferences(schema, query)
+	joined := map[string]bool{}
+	for alias, rel := range relations {
+		joinType, _ := rel["type"].(string)
+		isRequired, _ := rel["isRequired"].(bool)
+		if isRequired || !strings.Contains(strings.ToLower(joinType), "left") || q.isAliasReferenced(alias, references) {
+			joined[alias] = true
+		}
+	}
+
+	// the relation referenced by the conditions of the joined relation is also joined
+	for isChanged := true; isChanged; {
+		isChanged = false
+		for alias := range joined {
+			conditions, _ := relations[alias]["conditions"].([]map[string]any)
+			condReferences := q.condReferences(conditions)
+			for a := range relations {
+				if !joined[a] && a != alias && q.isAliasReferenced(a, condReferences) {
+					joined[a] = true
+					isChanged = true
+				}
+			}
+		}
+	}
+	return joined
+}
+
+// joinReferences returns the SQL expressions used by the query (selected fields, filters, sorts & groups)
+func (q *DBQuery) joinReferences(schema map[string]any, query url.Values) []string {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	references := []string{}
+
+	// selected fields, not used on counting rows
+	if !q.isCounting {
+		references = append(references, q.getSelect(schema, query)...)
+		if q.isSortedBySearchRank(schema, query) {
+			rankSQL, _ := q.searchRank(schema, query)
+			references = append(references, rankSQL)
+		}
+		for _, srt := range q.getSorts(schema, query) {
+			column, _ := srt["column"].(string)
+			references = append(references, column)
+		}
+	}
+
+	// groups
+	groups, _ := schema["groups"].(map[string]string)
+	for _, group := range groups {
+		references = append(references, group)
+	}
+	for _, k := range strings.Split(query.Get(QueryGroup), ",") {
+		group, _ := fields[k]["db"].(string)
+		references = append(references, group)
+	}
+
+	// filters
+	filters, _ := schema["filters"].([]map[string]any)
+	references = append(references, q.condReferences(filters)...)
+	searchFields, _ := q.searchFields(schema, query)
+	for _, field := range searchFields {
+		db, _ := field["db"].(string)
+		references = append(references, db)
+	}
+	filterKeys := [][2]string{}
+	for key, val := range query {
+		switch key {
+		case QueryOr:
+			for _, ov := range val {
+				orQueries := strings.Split(ov, QueryOrDelimiter)
+				if strings.Contains(ov, "||") {
+					orQueries = strings.Split(ov, "||")
+				}
+				for _, orQuery := range orQueries {
+					orQ, orVal, found := strings.Cut(orQuery, ":")
+					if !found {
+						orQ, orVal, _ = strings.Cut(orQuery, "=")
+					}
+					filterKeys = append(filterKeys, [2]string{orQ, orVal})
+				}
+			}
+		case QueryFilter:
+			if node, err := ParseFilter(val[0]); err == nil {
+				filterKeys = append(filterKeys, q.filterNodeKeys(node)...)
+			}
+		default:
+			filterKeys = append(filterKeys, [2]string{key, val[0]})
+		}
+	}
+	for _, kv := range filterKeys {
+		key, _ := url.QueryUnescape(kv[0])
+		isArrayField := false
+		for k, arrayField := range arrayFields {
+			if strings.HasPrefix(key, k+".0.") || strings.HasPrefix(key, k+".*.") {
+				// the array field sub query is correlated using the parent fields of the placeholders
+				isArrayField = true
+				arrayFilter, _ := arrayField["filter"].(string)
+				for _, v := range (String{}).GetVars(arrayFilter, "{", "}") {
+					db, _ := fields[v]["db"].(string)
+					references = append(references, db)
+				}
+			}
+		}
+		if isArrayField {
+			continue
+		}
+		cond := q.qsToHavingCond(key, kv[1], fields)
+		if cond["column1"] == nil {
+			cond = q.qsToCond(key, kv[1], fields, nil)
+		}
+		references = append(references, q.condReferences([]map[string]any{cond})...)
+	}
+	return references
+}
+
+// filterNodeKeys returns the query params key val of the comparisons of the QueryFilter expression
+func (q *DBQuery) filterNodeKeys(node *FilterNode) [][2]string {
+	if node.Op != "" {
+		keys := [][2]string{}
+		for _, child := range node.Children {
+			keys = append(keys, q.filterNodeKeys(child)...)
+		}
+		return keys
+	}
+	return [][2]string{{node.Field + "." + *filterOperators[node.Operator], ""}}
+}
+
+// condReferences returns the columns of the conditions
+func (q *DBQuery) condReferences(conds []map[string]any) []string {
+	references := []string{}
+	for _, cond := range conds {
+		column1, _ := cond["column1"].(string)
+		column2, _ := cond["column2"].(string)
+		references = append(references, column1, column2)
+	}
+	return references
+}
+
+// isAliasReferenced returns true if the table alias is used by one of the SQL expressions (ex: u.name, "u"."name" or coalesce(u.name,0))
+func (q *DBQuery) isAliasReferenced(alias string, references []string) bool {
+	prefixes := []string{alias + ".", `"` + alias + `".`, "`" + alias + "`.", "[" + alias + "]."}
+	for _, ref := range references {
+		for _, prefix := range prefixes {
+			for offset := 0; offset < len(ref); {
+				i := strings.Index(ref[offset:], prefix)
+				if i < 0 {
+					break
+				}
+				i += offset
+				if i == 0 || !isIdentifierChar(ref[i-1]) {
+					return true
+				}
+				offset = i + 1
+			}
+		}
+	}
+	return false
+}
+
+// isIdentifierChar returns true if c can be a part of the SQL identifier (ex: the table alias of the other table which ends with the alias)
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
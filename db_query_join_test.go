@@ -0,0 +1,97 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func TestDBQueryJoinPruning(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	toSQL := func(schema map[string]any, query url.Values) string {
+		return db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+			rows := []map[string]any{}
+			tx, _ = dq.Prepare(tx, schema, query)
+			tx = dq.SetSelect(tx, schema, query)
+			tx = dq.SetOrder(tx, schema, query)
+			return tx.Find(&rows)
+		})
+	}
+	schema := (&Article{}).GetSchema()
+	schema["queryPolicy"] = QueryPolicy{IsAllowField: true}
+	usersJoin := `LEFT JOIN "users" AS "u"`
+	reviewsJoin := `AS "tr" ON`
+
+	testCases := []struct {
+		query          url.Values
+		isUsersJoined  bool
+		isReviewJoined bool
+	}{
+		{url.Values{}, true, true},
+		{url.Values{QuerySelect: {"id,title"}}, false, false},
+		{url.Values{QuerySelect: {"id,author.name"}}, true, false},
+		{url.Values{QuerySelect: {"id"}, "author.email.$like": {"%@mail.com"}}, true, false},
+		{url.Values{QuerySelect: {"id"}, QuerySort: {"-total_review"}}, false, true},
+		{url.Values{QuerySelect: {"id"}, QueryOr: {"title=a|author.name=b"}}, true, false},
+		{url.Values{QuerySelect: {"id"}, QueryFilter: {"total_review gt 1"}}, false, true},
+		{url.Values{QuerySelect: {"id"}, QuerySearch: {"title,author.name:john"}}, true, false},
+		{url.Values{QuerySelect: {"id"}, QueryGroup: {"author.email"}}, true, false},
+		{url.Values{QuerySelect: {"id"}, "id": {"$field:u.name"}}, true, false},
+	}
+	for _, tc := range testCases {
+		sql := toSQL(schema, tc.query)
+		if strings.Contains(sql, usersJoin) != tc.isUsersJoined {
+			t.Errorf("Expected users joined [%v] for %v in:\n%v", tc.isUsersJoined, tc.query, sql)
+		}
+		if strings.Contains(sql, reviewsJoin) != tc.isReviewJoined {
+			t.Errorf("Expected reviews joined [%v] for %v in:\n%v", tc.isReviewJoined, tc.query, sql)
+		}
+	}
+
+	// inner join is always joined, the left join referenced by the joined relation conditions is also joined
+	category := (&Category{}).GetSchema()
+	relations, _ := category["relations"].(map[string]map[string]any)
+	relations["ac"]["conditions"] = []map[string]any{{"column1": "ac.category_id", "operator": "=", "column2": "c.id"}, {"column1": "ac.author_id", "operator": "=", "column2": "u.id"}}
+	sql := toSQL(category, url.Values{QuerySelect: {"id"}})
+	if !strings.Contains(sql, `INNER JOIN "articles_categories" AS "ac"`) || !strings.Contains(sql, usersJoin) {
+		t.Errorf("Expected inner join and the referenced left join in:\n%v", sql)
+	}
+
+	// required left join is always joined
+	schema = (&Article{}).GetSchema()
+	relations, _ = schema["relations"].(map[string]map[string]any)
+	relations["u"]["isRequired"] = true
+	sql = toSQL(schema, url.Values{QuerySelect: {"id"}})
+	if !strings.Contains(sql, usersJoin) || strings.Contains(sql, reviewsJoin) {
+		t.Errorf("Expected only required left join in:\n%v", sql)
+	}
+}
+
+func TestDBQueryIsAliasReferenced(t *testing.T) {
+	q := &DBQuery{}
+	testCases := []struct {
+		reference string
+		expected  bool
+	}{
+		{"u.name", true},
+		{`"u"."name"`, true},
+		{"`u`.`name`", true},
+		{"[u].[name]", true},
+		{"coalesce(u.name,0)", true},
+		{"au.name", false},
+		{`"au"."name"`, false},
+		{"au.name || u.email", true},
+		{"user.name", false},
+	}
+	for _, tc := range testCases {
+		if got := q.isAliasReferenced("u", []string{tc.reference}); got != tc.expected {
+			t.Errorf("isAliasReferenced(%q) expected [%v], got [%v]", tc.reference, tc.expected, got)
+		}
+	}
+}
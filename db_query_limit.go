@@ -0,0 +1,213 @@
+package grest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// QueryLimits is the query cost guardrails of the query params, the violated limit is rejected with bad request error
+//
+// the DBQuery.Limits is used if setted, otherwise QueryDefaultLimits is used, the zero value of the max limits means unlimited.
+//
+//	q := &grest.DBQuery{DB: db, Limits: &grest.QueryLimits{MaxConditions: 20, MaxInValues: 500, MaxOrBranches: 10, MaxIncludeDepth: 2, Timeout: 10 * time.Second}}
+type QueryLimits struct {
+	MaxConditions            int           // max number of the filters (including each $or branch, each $filter comparison and $search), the unknown keys are not counted
+	MaxInValues              int           // max number of the $in or $nin values of each filter
+	MaxOrBranches            int           // max number of the branches of each $or or each "or" of $filter
+	MaxIncludeDepth          int           // max depth of the nested includes (ex: orders.items.product_options is 3)
+	IsAllowDisablePagination bool          // allow QueryDisablePagination
+	Timeout                  time.Duration // context deadline of Find, Count and FindEach (including the array fields queries)
+	StatementTimeout         time.Duration // db statement timeout, the query is executed in a transaction, see Dialect.StatementTimeout
+}
+
+// QueryDefaultLimits is the default QueryLimits of DBQuery
+var QueryDefaultLimits = QueryLimits{
+	MaxIncludeDepth:          3,
+	IsAllowDisablePagination: true,
+}
+
+// limits returns the query limits of the DBQuery
+func (q *DBQuery) limits() QueryLimits {
+	if q.Limits != nil {
+		return *q.Limits
+	}
+	return QueryDefaultLimits
+}
+
+// checkQueryLimits rejects the query params exceeding the QueryLimits
+func (q *DBQuery) checkQueryLimits(schema map[string]any, query url.Values) error {
+	limits := q.limits()
+	if !limits.IsAllowDisablePagination && query.Get(QueryDisablePagination) == "true" {
+		return NewError(http.StatusBadRequest, fmt.Sprintf("The %s is not allowed.", QueryDisablePagination),
+			map[string]any{QueryDisablePagination: map[string]any{"allowed": false}})
+	}
+	if limits.MaxIncludeDepth > 0 {
+		if err := q.checkIncludeDepth(schema, query, limits.MaxIncludeDepth); err != nil {
+			return err
+		}
+	}
+
+	conditions := 0
+	checkIn := func(param, key, val string) error {
+		if limits.MaxInValues <= 0 {
+			return nil
+		}
+		key, _ = url.QueryUnescape(key)
+		if !strings.HasSuffix(key, "."+QueryOptIn) && !strings.HasSuffix(key, "."+QueryOptNotIn) {
+			return nil
+		}
+		return q.checkInValues(param, key, len(strings.Split(val, ",")), limits.MaxInValues)
+	}
+	for key, vals := range query {
+		switch key {
+		case QueryLimit, QueryOffset, QueryPage, QueryDisablePagination, QueryAfter, QueryBefore,
+			QuerySelect, QuerySort, QueryGroup, QueryInclude, QueryExclude:
+		case QuerySearch:
+			conditions++
+		case QueryOr:
+			for _, ov := range vals {
+				orQueries := strings.Split(ov, QueryOrDelimiter)
+				if strings.Contains(ov, "||") {
+					orQueries = strings.Split(ov, "||")
+				}
+				if limits.MaxOrBranches > 0 && len(orQueries) > limits.MaxOrBranches {
+					return q.limitError(key, fmt.Sprintf("The %s branches must not be greater than %d.", key, limits.MaxOrBranches), limits.MaxOrBranches)
+				}
+				for _, orQuery := range orQueries {
+					orQ, orVal, found := strings.Cut(orQuery, ":")
+					if !found {
+						orQ, orVal, found = strings.Cut(orQuery, "=")
+					}
+					if found && q.isCondQueryParam(schema, orQ, orVal) {
+						conditions++
+					}
+					if err := checkIn(key, orQ, orVal); err != nil {
+						return err
+					}
+				}
+			}
+		case QueryFilter:
+			node, err := ParseFilter(vals[0])
+			if err != nil {
+				// the syntax error is returned by SetWhere
+				continue
+			}
+			n, err := q.checkFilterNodeLimits(node, limits)
+			if err != nil {
+				return err
+			}
+			conditions += n
+		default:
+			if !q.isCondQueryParam(schema, key, vals[0]) {
+				continue
+			}
+			conditions++
+			if err := checkIn(key, key, vals[0]); err != nil {
+				return err
+			}
+		}
+	}
+	if limits.MaxConditions > 0 && conditions > limits.MaxConditions {
+		return q.limitError("conditions", fmt.Sprintf("The number of conditions must not be greater than %d.", limits.MaxConditions), limits.MaxConditions)
+	}
+	return nil
+}
+
+// isCondQueryParam returns true if the query params key val is compiled to a condition by SetWhere,
+// the unknown keys (ex: cache buster) are ignored by SetWhere, so they are not counted as the conditions
+func (q *DBQuery) isCondQueryParam(schema map[string]any, key, val string) bool {
+	key, _ = url.QueryUnescape(key)
+	if q.isScopedQueryParam(key) {
+		return false
+	}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for k := range arrayFields {
+		if strings.HasPrefix(key, k+".0.") || strings.HasPrefix(key, k+".*.") {
+			return true
+		}
+	}
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	if cond := q.qsToHavingCond(key, val, fields); cond["column1"] != nil {
+		return true
+	}
+	return q.qsToCond(key, val, fields, nil)["column1"] != nil
+}
+
+// checkFilterNodeLimits checks the "or" branches and the "in" values of the QueryFilter expression, returns the number of the comparisons
+func (q *DBQuery) checkFilterNodeLimits(node *FilterNode, limits QueryLimits) (int, error) {
+	if node.Op != "" {
+		if node.Op == "or" && limits.MaxOrBranches > 0 && len(node.Children) > limits.MaxOrBranches {
+			return 0, q.limitError(QueryFilter, fmt.Sprintf("The %s branches must not be greater than %d.", QueryFilter, limits.MaxOrBranches), limits.MaxOrBranches)
+		}
+		conditions := 0
+		for _, child := range node.Children {
+			n, err := q.checkFilterNodeLimits(child, limits)
+			if err != nil {
+				return 0, err
+			}
+			conditions += n
+		}
+		return conditions, nil
+	}
+	if values, ok := node.Value.([]string); ok && limits.MaxInValues > 0 {
+		if err := q.checkInValues(QueryFilter, node.Field, len(values), limits.MaxInValues); err != nil {
+			return 0, err
+		}
+	}
+	return 1, nil
+}
+
+// checkInValues rejects the $in or $nin values more than maxValues
+func (q *DBQuery) checkInValues(param, key string, values, maxValues int) error {
+	if values <= maxValues {
+		return nil
+	}
+	return q.limitError(param, fmt.Sprintf("The values of %s must not be greater than %d.", key, maxValues), maxValues)
+}
+
+// limitError returns bad request error of the violated query limit
+func (q *DBQuery) limitError(param, message string, maxValue int) error {
+	return NewError(http.StatusBadRequest, message, map[string]any{param: map[string]any{"max": maxValue}})
+}
+
+// withTimeout runs fn with the context deadline (QueryLimits.Timeout) and the db statement timeout (QueryLimits.StatementTimeout),
+// the DB is replaced during fn so the internal queries (ex: array fields query) are also limited
+func (q *DBQuery) withTimeout(fn func() error) error {
+	limits := q.limits()
+	if q.isInternal || (limits.Timeout <= 0 && limits.StatementTimeout <= 0) {
+		return fn()
+	}
+	db := q.DB
+	defer func() { q.DB = db }()
+	if limits.Timeout > 0 {
+		ctx := db.Statement.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctx, cancel := context.WithTimeout(ctx, limits.Timeout)
+		defer cancel()
+		q.DB = db.WithContext(ctx)
+	}
+
+	setSQL, resetSQL := q.Dialect().StatementTimeout(limits.StatementTimeout.Milliseconds())
+	if limits.StatementTimeout <= 0 || setSQL == "" {
+		return fn()
+	}
+	return q.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(setSQL).Error; err != nil {
+			return q.toError(err)
+		}
+		q.DB = tx
+		err := fn()
+		if resetSQL != "" {
+			tx.Exec(resetSQL)
+		}
+		return err
+	})
+}
@@ -0,0 +1,89 @@
+package grest
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDBQueryLimits(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	schema := (&Article{}).GetSchema()
+	limits := &QueryLimits{MaxConditions: 4, MaxInValues: 3, MaxOrBranches: 2, MaxIncludeDepth: 1}
+	dq := &DBQuery{DB: db, Limits: limits}
+
+	testCases := []struct {
+		query    url.Values
+		expected string
+	}{
+		{url.Values{"id.$in": {"1,2,3"}, "title": {"a"}, QuerySort: {"title"}, "categories.*." + QuerySort: {"code"}}, ""},
+		{url.Values{"id.$in": {"1,2,3,4"}}, "values of id.$in"},
+		{url.Values{"id.$nin": {"1,2,3,4"}}, "values of id.$nin"},
+		{url.Values{QueryOr: {"title=a|title=b|title=c"}}, "$or branches"},
+		{url.Values{QueryOr: {"title=a|id.$in:1,2,3,4"}}, "values of id.$in"},
+		{url.Values{QueryFilter: {"title eq 'a' or title eq 'b' or title eq 'c'"}}, "$filter branches"},
+		{url.Values{QueryFilter: {"id in ('1','2','3','4')"}}, "values of id"},
+		{url.Values{"title": {"1"}, "content": {"1"}, QueryOr: {"id=1|is_active=1"}, QueryFilter: {"title eq 1"}}, "number of conditions"},
+		{url.Values{"title": {"1"}, "categories.0.code": {"1"}, "$count.$gt": {"1"}, QuerySearch: {"title:foo"}, QueryFilter: {"title eq 1"}}, "number of conditions"},
+		{url.Values{"title": {"1"}, "content": {"1"}, "_": {"1700000000"}, "unknown": {"1"}, QueryOr: {"id=1|foo=1"}}, ""},
+		{url.Values{QueryDisablePagination: {"true"}}, QueryDisablePagination},
+		{url.Values{QueryInclude: {"categories"}}, ""},
+	}
+	for _, tc := range testCases {
+		err := dq.checkQueryLimits(schema, tc.query)
+		if tc.expected == "" {
+			if err != nil {
+				t.Errorf("Expected no error for %v, got [%v]", tc.query, err)
+			}
+			continue
+		}
+		if e, ok := err.(*Error); !ok || e.Code != 400 || !strings.Contains(e.Message, tc.expected) {
+			t.Errorf("Expected 400 error contains [%v] for %v, got [%v]", tc.expected, tc.query, err)
+		}
+	}
+
+	// the default limits allow disabling pagination
+	dq.Limits = nil
+	if err := dq.checkQueryLimits(schema, url.Values{QueryDisablePagination: {"true"}}); err != nil {
+		t.Errorf("Expected no error with the default limits, got [%v]", err)
+	}
+}
+
+func TestDBQueryTimeout(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db, Limits: &QueryLimits{StatementTimeout: 1500 * time.Millisecond}}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL statement_timeout = 1500`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	mock.ExpectCommit()
+	rows, err := dq.Find((&Article{}).GetSchema(), url.Values{})
+	if err != nil || len(rows) != 1 {
+		t.Fatalf("Expected 1 row, got [%v] [%v]", rows, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+	if dq.DB != db {
+		t.Errorf("Expected the DB is restored after the query")
+	}
+
+	dq = &DBQuery{DB: db, Limits: &QueryLimits{Timeout: 10 * time.Millisecond}}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillDelayFor(100 * time.Millisecond).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	_, err = dq.Find((&Article{}).GetSchema(), url.Values{})
+	if err == nil {
+		t.Errorf("Expected timeout error")
+	}
+}
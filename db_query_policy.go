@@ -0,0 +1,303 @@
+package grest
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// QueryPolicy restricts the fields and operators that clients can use on query params, in addition to the filter, sort & group struct tags.
+//
+// the policy is setted per model by implementing QueryPolicy method.
+//
+// example :
+//
+//	func (m *Contact) QueryPolicy() grest.QueryPolicy {
+//		return grest.QueryPolicy{
+//			Filters: map[string][]string{"id": nil, "name": {"$eq", "$ilike"}},
+//			Sorts:   []string{"name", "created_at"},
+//		}
+//	}
+type QueryPolicy struct {
+	// filterable field keys with the allowed operators (nil or empty for all operators), nil to allow all fields
+	Filters map[string][]string
+
+	// sortable field keys, nil to allow all fields
+	Sorts []string
+
+	// groupable field keys, nil to allow all fields
+	Groups []string
+
+	// allow raw db column using QueryDbField
+	IsAllowDbField bool
+
+	// allow comparing with another column using QueryField
+	IsAllowField bool
+}
+
+// queryPolicy returns the QueryPolicy of the schema
+func (q *DBQuery) queryPolicy(schema map[string]any) QueryPolicy {
+	policy, _ := schema["queryPolicy"].(QueryPolicy)
+	return policy
+}
+
+// internal returns a copy of DBQuery to run the internal query, the client query params are already checked by the parent query
+func (q *DBQuery) internal() *DBQuery {
+	internal := *q
+	internal.isInternal = true
+	return &internal
+}
+
+// checkQueryPolicy checks the filters, sorts and groups of the query params against the field tags and the QueryPolicy of the schema,
+// bad request *Error is returned on violation
+func (q *DBQuery) checkQueryPolicy(schema map[string]any, query url.Values) error {
+	for key, val := range query {
+		switch key {
+		case QuerySort:
+			for _, s := range strings.Split(val[0], ",") {
+				s = strings.TrimSuffix(strings.TrimPrefix(s, "-"), ":i")
+				if !q.isSortable(schema, s) {
+					return q.policyError(QuerySort, s, "sort")
+				}
+			}
+		case QueryGroup:
+			for _, g := range strings.Split(val[0], ",") {
+				if !q.isGroupable(schema, g) {
+					return q.policyError(QueryGroup, g, "group")
+				}
+			}
+		case QuerySelect:
+			// the selected fields are grouped when an aggregate is selected, see SetGroup
+			selects := strings.Split(val[0], ",")
+			isAggFunc := slices.ContainsFunc(selects, func(k string) bool {
+				return q.qsToAggFuncSQL(strings.Split(k, ":")[0]) != ""
+			})
+			for _, k := range selects {
+				if isAggFunc && q.qsToAggFuncSQL(strings.Split(k, ":")[0]) == "" && !q.isGroupable(schema, k) {
+					return q.policyError(QuerySelect, k, "group")
+				}
+			}
+		case QueryOr:
+			for _, ov := range val {
+				orQueries := strings.Split(ov, QueryOrDelimiter)
+				if strings.Contains(ov, "||") {
+					orQueries = strings.Split(ov, "||")
+				}
+				for _, orQuery := range orQueries {
+					orQ, orVal, found := strings.Cut(orQuery, ":")
+					if !found {
+						orQ, orVal, found = strings.Cut(orQuery, "=")
+					}
+					if found {
+						if err := q.checkFilterPolicy(schema, QueryOr, orQ, orVal); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		case QueryFilter:
+			node, err := ParseFilter(val[0])
+			if err == nil {
+				if err := q.checkFilterNodePolicy(schema, node); err != nil {
+					return err
+				}
+			}
+		case QuerySearch:
+			fields, _ := q.searchFields(schema, query)
+			for _, field := range fields {
+				fieldKey, _ := field["as"].(string)
+				if !q.isFilterable(schema, fieldKey, QueryOptInsensitiveLike) {
+					return q.policyError(QuerySearch, fieldKey, "filter")
+				}
+			}
+		default:
+			if err := q.checkFilterPolicy(schema, key, key, val[0]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkFilterNodePolicy checks the comparisons of the QueryFilter expression
+func (q *DBQuery) checkFilterNodePolicy(schema map[string]any, node *FilterNode) error {
+	if node.Op != "" {
+		for _, child := range node.Children {
+			if err := q.checkFilterNodePolicy(schema, child); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return q.checkFilterPolicy(schema, QueryFilter, node.Field+"."+*filterOperators[node.Operator], "")
+}
+
+// checkFilterPolicy checks the filter key val of the query params (param), the key is ignored if it is not a filter of the schema fields
+//
+// the QueryField and QueryDbField are detected the same way as qsToCond : QueryField anywhere in the value, QueryDbField as the first sub key (with or without cast)
+func (q *DBQuery) checkFilterPolicy(schema map[string]any, param, key, val string) error {
+	policy := q.queryPolicy(schema)
+	_, ref, isFieldRef := strings.Cut(val, QueryField+":")
+	if isFieldRef && !policy.IsAllowField {
+		return q.policyError(param, QueryField, "filter")
+	}
+	key, _ = url.QueryUnescape(key)
+
+	// array fields filter is checked using the array schema
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for k, arrayField := range arrayFields {
+		for _, sep := range []string{".0.", ".*."} {
+			if childKey, ok := strings.CutPrefix(key, k+sep); ok {
+				arraySchema, _ := arrayField["schema"].(map[string]any)
+				return q.checkFilterPolicy(arraySchema, param, childKey, val)
+			}
+		}
+	}
+
+	firstSubkey, _, _ := strings.Cut(key, ".")
+	firstSubkey, _, _ = strings.Cut(firstSubkey, QueryCast)
+	if firstSubkey == QueryDbField && !policy.IsAllowDbField {
+		return q.policyError(param, QueryDbField, "filter")
+	}
+
+	fieldKey, optKey := q.qsToFieldKey(schema, key)
+	if fieldKey != "" && !q.isFilterable(schema, fieldKey, optKey) {
+		return q.policyError(param, fieldKey, "filter")
+	}
+
+	// the referenced field must be a filterable schema field too
+	if isFieldRef {
+		refKey := q.fieldRefKey(schema, ref)
+		if refKey == "" {
+			return q.policyError(param, QueryField, "filter")
+		}
+		if !q.isFilterable(schema, refKey, optKey) {
+			return q.policyError(param, refKey, "filter")
+		}
+	}
+	return nil
+}
+
+// fieldRefKey returns the key of the schema field referenced by QueryField (by the field key or its db column), empty string if it is not a schema field
+func (q *DBQuery) fieldRefKey(schema map[string]any, ref string) string {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	if fields[ref] != nil {
+		return ref
+	}
+	for k, v := range fields {
+		if db, _ := v["db"].(string); db == ref {
+			return k
+		}
+	}
+	return ""
+}
+
+// qsToFieldKey returns the field key and the operator key of the filter query params key, empty field key if it is not a schema field
+//
+// ex: name.$ilike > name, $ilike; created_at:date.$gte > created_at, $gte; detail.tags.$eq > detail, $eq; $sum:sold.$gt > sold, $gt
+func (q *DBQuery) qsToFieldKey(schema map[string]any, key string) (string, string) {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	optKey := QueryOptEqual
+	if i := strings.LastIndex(key, "."); i >= 0 && q.qsToOptSQL(key[i+1:]) != "" {
+		optKey = key[i+1:]
+		key = key[:i]
+	}
+
+	// aggregate filter
+	aggKey, aggField, isAgg := strings.Cut(key, QueryCast)
+	if isAgg && q.qsToAggFuncSQL(aggKey) != "" {
+		aggField, _, _ = strings.Cut(aggField, QueryCast)
+		return aggField, optKey
+	}
+
+	// remove cast
+	if i := strings.Index(key, QueryCast); i >= 0 {
+		rest := key[i+1:]
+		castKey, rest, _ := strings.Cut(rest, ".")
+		if q.qsToStandardSQLDataType(castKey) != "" {
+			key = key[:i]
+			if rest != "" {
+				key += "." + rest
+			}
+		}
+	}
+
+	if fields[key] != nil {
+		return key, optKey
+	}
+	for k, v := range fields {
+		fType, _ := v["type"].(string)
+		if strings.HasPrefix(key, k+".") && strings.Contains(strings.ToLower(fType), "json") {
+			return k, optKey
+		}
+	}
+	return "", optKey
+}
+
+// isFilterable returns true if the field can be filtered using the operator key, based on the filter tag and the QueryPolicy
+//
+// the filter tag is "-" to disable filter, or comma separated allowed operator keys (ex: filter:"$eq,$in")
+func (q *DBQuery) isFilterable(schema map[string]any, fieldKey, optKey string) bool {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	if filterTag, ok := fields[fieldKey]["filter"].(string); ok {
+		if filterTag == "-" {
+			return false
+		}
+		if filterTag != "" && !slices.Contains(strings.Split(filterTag, ","), optKey) {
+			return false
+		}
+	}
+	policy := q.queryPolicy(schema)
+	if policy.Filters != nil {
+		opts, ok := policy.Filters[fieldKey]
+		if !ok || (len(opts) > 0 && !slices.Contains(opts, optKey)) {
+			return false
+		}
+	}
+	return true
+}
+
+// isSortable returns true if the sort key can be used, based on the sort tag and the QueryPolicy
+func (q *DBQuery) isSortable(schema map[string]any, key string) bool {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	fieldKey := key
+	if _, aggField, isAgg := strings.Cut(key, QueryCast); isAgg {
+		fieldKey = aggField
+	}
+	if fields[fieldKey] == nil {
+		// not a field (ex: count_all, $search_rank or json sub key), the json field is checked
+		for k := range fields {
+			if strings.HasPrefix(fieldKey, k+".") {
+				fieldKey = k
+			}
+		}
+		if fields[fieldKey] == nil {
+			return true
+		}
+	}
+	if sortTag, _ := fields[fieldKey]["sort"].(string); sortTag == "-" {
+		return false
+	}
+	policy := q.queryPolicy(schema)
+	return policy.Sorts == nil || slices.Contains(policy.Sorts, fieldKey)
+}
+
+// isGroupable returns true if the field can be grouped, based on the group tag and the QueryPolicy
+func (q *DBQuery) isGroupable(schema map[string]any, fieldKey string) bool {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	if fields[fieldKey] == nil {
+		return true
+	}
+	if groupTag, _ := fields[fieldKey]["group"].(string); groupTag == "-" {
+		return false
+	}
+	policy := q.queryPolicy(schema)
+	return policy.Groups == nil || slices.Contains(policy.Groups, fieldKey)
+}
+
+// policyError returns bad request *Error of the not allowed query params, the detail is keyed by the query params
+func (q *DBQuery) policyError(param, field, rule string) *Error {
+	msg := "The " + field + " " + rule + " is not allowed."
+	return NewError(http.StatusBadRequest, msg, map[string]any{param: map[string]any{rule: msg}})
+}
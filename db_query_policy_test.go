@@ -0,0 +1,122 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type Ticket struct {
+	Model
+	ID      NullUUID   `json:"id"      db:"t.id"`
+	Subject NullString `json:"subject" db:"t.subject" filter:"$eq,$ilike"`
+	Secret  NullString `json:"secret"  db:"t.secret"  filter:"-" sort:"-" group:"-"`
+	Status  NullString `json:"status"  db:"t.status"`
+	Detail  NullJSON   `json:"detail"  db:"t.detail"`
+}
+
+func (Ticket) TableName() string {
+	return "tickets"
+}
+
+func (Ticket) TableAliasName() string {
+	return "t"
+}
+
+func (m *Ticket) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+func (m *Ticket) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+func (m *Ticket) QueryPolicy() QueryPolicy {
+	return QueryPolicy{
+		Filters: map[string][]string{"id": nil, "subject": nil, "secret": nil, "detail": {QueryOptEqual}},
+		Sorts:   []string{"subject", "status"},
+	}
+}
+
+func TestDBQueryPolicy(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	schema := (&Ticket{}).GetSchema()
+
+	testCases := []struct {
+		key, val  string
+		isAllowed bool
+	}{
+		{"id", "1", true},
+		{"subject.$ilike", "%foo%", true},
+		{"subject.$gt", "foo", false},
+		{"secret", "foo", false},
+		{"status", "open", false},
+		{"detail.foo.bar", "baz", true},
+		{"detail.foo.bar.$like", "baz", false},
+		{"id", "$field:status", false},
+		{"$db_field.t.secret", "foo", false},
+		{"$db_field:int.(select 1)", "foo", false},
+		{"%24db_field:text.t.secret", "foo", false},
+		{"id", "x$field:t.secret", false},
+		{QuerySort, "-subject", true},
+		{QuerySort, "id", false},
+		{QuerySort, "-secret", false},
+		{QueryGroup, "secret", false},
+		{QuerySelect, "secret", true},
+		{QuerySelect, "status,$count", true},
+		{QuerySelect, "secret,$count", false},
+		{QuerySelect, "status,$sum:secret", true},
+		{QueryOr, "id=1|status=open", false},
+		{QueryFilter, "id eq 1 or (subject eq 'foo' and not secret eq 'bar')", false},
+		{QuerySearch, "subject,status:foo", false},
+		{QueryPage, "2", true},
+	}
+	for _, tc := range testCases {
+		err := dq.checkQueryPolicy(schema, url.Values{tc.key: {tc.val}})
+		if tc.isAllowed && err != nil {
+			t.Errorf("Expected %v=%v is allowed, got [%v]", tc.key, tc.val, err)
+		}
+		if !tc.isAllowed {
+			e, ok := err.(*Error)
+			if !ok || e.Code != 400 {
+				t.Errorf("Expected %v=%v is not allowed with 400 error, got [%v]", tc.key, tc.val, err)
+			} else if detail, _ := e.Detail.(map[string]any); detail[tc.key] == nil {
+				t.Errorf("Expected detail keyed by %v, got [%v]", tc.key, e.Detail)
+			}
+		}
+	}
+
+	// the field referenced by QueryField must be a filterable schema field
+	schema["queryPolicy"] = QueryPolicy{IsAllowField: true, Filters: map[string][]string{"id": nil, "subject": nil, "secret": nil}}
+	for val, isAllowed := range map[string]bool{"$field:t.subject": true, "$field:subject": true, "x$field:t.secret": false, "$field:(select 1)": false} {
+		if err := dq.checkQueryPolicy(schema, url.Values{"id": {val}}); (err == nil) != isAllowed {
+			t.Errorf("Expected id=%v is allowed [%v], got [%v]", val, isAllowed, err)
+		}
+	}
+	schema = (&Ticket{}).GetSchema()
+
+	_, err = dq.Find(schema, url.Values{"secret": {"foo"}})
+	if e, ok := err.(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error, got [%v]", err)
+	}
+	if sql := dq.ToSQL(schema, url.Values{QuerySelect: {"secret,$count"}}); strings.Contains(sql, "GROUP BY") || dq.Err == nil {
+		t.Errorf("Expected the selected field is not grouped, got [%v]:\n%v", dq.Err, sql)
+	}
+	dq.Err = nil
+
+	// array fields filter is checked using the array schema policy
+	orderSchema := (&Order{}).GetSchema()
+	itemSchema, _ := orderSchema["arrayFields"].(map[string]map[string]any)["items"]["schema"].(map[string]any)
+	itemSchema["queryPolicy"] = QueryPolicy{Filters: map[string][]string{"product": nil}}
+	if err := dq.checkQueryPolicy(orderSchema, url.Values{"items.0.product": {"book"}}); err != nil {
+		t.Errorf("Expected items.0.product is allowed, got [%v]", err)
+	}
+	if err := dq.checkQueryPolicy(orderSchema, url.Values{"items.*.qty.$gt": {"1"}}); err == nil {
+		t.Errorf("Expected items.*.qty is not allowed")
+	}
+}
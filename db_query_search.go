@@ -0,0 +1,239 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+)
+
+// SearchStrategy builds the where condition and the rank of QuerySearch.
+//
+// the search strategy is selected per model by implementing SearchStrategy method, LikeSearch is used if not implemented.
+//
+// example :
+//
+//	func (m *Contact) SearchStrategy() grest.SearchStrategy {
+//		return grest.PostgresFullTextSearch{Config: "english"}
+//	}
+type SearchStrategy interface {
+	// Where returns where SQL string and args to search the value in the fields (the schema fields option, see Model.Fields)
+	Where(q *DBQuery, fields []map[string]any, value string) (string, []any)
+
+	// Rank returns SQL string and args of the search relevance (higher is more relevant), empty string if ranking is not supported
+	Rank(q *DBQuery, fields []map[string]any, value string) (string, []any)
+}
+
+// LikeSearch search using case insensitive LIKE on each field, joined by OR
+type LikeSearch struct{}
+
+// Where returns LIKE conditions joined by OR
+func (LikeSearch) Where(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	wheres := []string{}
+	args := []any{}
+	for _, field := range fields {
+		whereSQL, arg := q.condToWhereSQL(map[string]any{
+			"column1":           field["db"],
+			"column1type":       field["type"],
+			"operator":          "LIKE",
+			"isCaseInsensitive": true,
+			"value":             value,
+		})
+		wheres = append(wheres, whereSQL)
+		args = append(args, arg)
+	}
+	return strings.Join(wheres, " OR "), args
+}
+
+// Rank returns empty string because LIKE has no relevance
+func (LikeSearch) Rank(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	return "", nil
+}
+
+// PostgresFullTextSearch search using to_tsvector @@ plainto_tsquery and rank using ts_rank
+//
+// Config is the text search configuration (default is simple),
+// Column is the tsvector column (ex: p.search_vector) to use the full text index instead of the tsvector of the fields
+type PostgresFullTextSearch struct {
+	Config string
+	Column string
+}
+
+// Where returns to_tsvector @@ plainto_tsquery condition
+func (s PostgresFullTextSearch) Where(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	return s.document(q, fields) + " @@ " + s.query(), []any{value}
+}
+
+// Rank returns ts_rank of the document and the query
+func (s PostgresFullTextSearch) Rank(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	return "ts_rank(" + s.document(q, fields) + ", " + s.query() + ")", []any{value}
+}
+
+func (s PostgresFullTextSearch) config() string {
+	if s.Config == "" {
+		return "'simple'"
+	}
+	return "'" + s.Config + "'"
+}
+
+func (s PostgresFullTextSearch) query() string {
+	return "plainto_tsquery(" + s.config() + ", ?)"
+}
+
+// document returns the tsvector column, or the tsvector of the fields concatenated by space
+func (s PostgresFullTextSearch) document(q *DBQuery, fields []map[string]any) string {
+	if s.Column != "" {
+		return q.DB.Statement.Quote(s.Column)
+	}
+	columns := []string{}
+	for _, field := range fields {
+		column := q.searchColumnSQL(field)
+		fieldType, _ := field["type"].(string)
+		if !strings.Contains(strings.ToLower(fieldType), "string") && !strings.Contains(strings.ToLower(fieldType), "text") {
+			column = "CAST(" + column + " AS text)"
+		}
+		columns = append(columns, "coalesce("+column+", '')")
+	}
+	return "to_tsvector(" + s.config() + ", " + strings.Join(columns, " || ' ' || ") + ")"
+}
+
+// MySQLFullTextSearch search and rank using MATCH AGAINST, the fields must be covered by a FULLTEXT index
+//
+// Mode is the search modifier (default is IN NATURAL LANGUAGE MODE)
+type MySQLFullTextSearch struct {
+	Mode string
+}
+
+// Where returns MATCH AGAINST condition
+func (s MySQLFullTextSearch) Where(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	return s.match(q, fields), []any{value}
+}
+
+// Rank returns MATCH AGAINST relevance
+func (s MySQLFullTextSearch) Rank(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	return s.match(q, fields), []any{value}
+}
+
+func (s MySQLFullTextSearch) match(q *DBQuery, fields []map[string]any) string {
+	mode := s.Mode
+	if mode == "" {
+		mode = "IN NATURAL LANGUAGE MODE"
+	}
+	columns := []string{}
+	for _, field := range fields {
+		columns = append(columns, q.searchColumnSQL(field))
+	}
+	return "MATCH (" + strings.Join(columns, ", ") + ") AGAINST (? " + mode + ")"
+}
+
+// SQLiteFTS5Search search and rank using SQLite FTS5 virtual table
+//
+// Table is the FTS5 table name, the FTS5 column names are the same as the main table column names,
+// Key is the main table column (ex: p.rowid) linked to the FTS5 rowid (default is rowid)
+type SQLiteFTS5Search struct {
+	Table string
+	Key   string
+}
+
+// Where returns the key in FTS5 MATCH sub query condition
+func (s SQLiteFTS5Search) Where(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	table := q.DB.Statement.Quote(s.Table)
+	return s.key(q) + " IN (SELECT rowid FROM " + table + " WHERE " + table + " MATCH ?)", []any{s.match(fields, value)}
+}
+
+// Rank returns the negative FTS5 rank (bm25) so higher is more relevant
+func (s SQLiteFTS5Search) Rank(q *DBQuery, fields []map[string]any, value string) (string, []any) {
+	table := q.DB.Statement.Quote(s.Table)
+	return "(SELECT -rank FROM " + table + " WHERE " + table + " MATCH ? AND rowid = " + s.key(q) + ")", []any{s.match(fields, value)}
+}
+
+func (s SQLiteFTS5Search) key(q *DBQuery) string {
+	if s.Key == "" {
+		return "rowid"
+	}
+	return q.DB.Statement.Quote(s.Key)
+}
+
+// match returns FTS5 query of the value as a string, filtered by the column names of the fields
+func (s SQLiteFTS5Search) match(fields []map[string]any, value string) string {
+	columns := []string{}
+	for _, field := range fields {
+		db, _ := field["db"].(string)
+		_, column, _ := strings.Cut(db, ".")
+		if !columnNameRegexp.MatchString(column) {
+			columns = nil
+			break
+		}
+		columns = append(columns, column)
+	}
+	match := `"` + strings.ReplaceAll(value, `"`, `""`) + `"`
+	if len(columns) > 0 {
+		match = "{" + strings.Join(columns, " ") + "} : " + match
+	}
+	return match
+}
+
+// searchStrategy returns the search strategy of the schema, LikeSearch is returned if not setted
+func (q *DBQuery) searchStrategy(schema map[string]any) SearchStrategy {
+	if s, ok := schema["searchStrategy"].(SearchStrategy); ok && s != nil {
+		return s
+	}
+	return LikeSearch{}
+}
+
+// searchFields returns the fields option of QuerySearch fields and the search value
+//
+// ex: $search=code,name:john or $search=code,name=john
+func (q *DBQuery) searchFields(schema map[string]any, query url.Values) ([]map[string]any, string) {
+	searchKey, searchVal, found := strings.Cut(query.Get(QuerySearch), ":")
+	if !found {
+		searchKey, searchVal, found = strings.Cut(query.Get(QuerySearch), "=")
+	}
+	if !found || searchVal == "" {
+		return nil, ""
+	}
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	searchFields := []map[string]any{}
+	for _, k := range strings.Split(searchKey, ",") {
+		if field, ok := fields[k]; ok && field["db"] != nil {
+			searchFields = append(searchFields, field)
+		}
+	}
+	return searchFields, searchVal
+}
+
+// searchWhere returns where SQL string and args of QuerySearch
+func (q *DBQuery) searchWhere(schema map[string]any, query url.Values) (string, []any) {
+	fields, value := q.searchFields(schema, query)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return q.searchStrategy(schema).Where(q, fields, value)
+}
+
+// searchRank returns rank SQL string and args of QuerySearch, empty string if not searched or not supported
+func (q *DBQuery) searchRank(schema map[string]any, query url.Values) (string, []any) {
+	fields, value := q.searchFields(schema, query)
+	if len(fields) == 0 {
+		return "", nil
+	}
+	return q.searchStrategy(schema).Rank(q, fields, value)
+}
+
+// isSortedBySearchRank returns true if QuerySort contains QuerySearchRank and the search rank is supported
+func (q *DBQuery) isSortedBySearchRank(schema map[string]any, query url.Values) bool {
+	for _, s := range strings.Split(query.Get(QuerySort), ",") {
+		if strings.TrimPrefix(s, "-") == QuerySearchRank {
+			rankSQL, _ := q.searchRank(schema, query)
+			return rankSQL != ""
+		}
+	}
+	return false
+}
+
+// searchColumnSQL returns quoted column of the field
+func (q *DBQuery) searchColumnSQL(field map[string]any) string {
+	column, _ := field["db"].(string)
+	if !strings.Contains(column, " ") && !strings.Contains(column, "(") {
+		column = q.DB.Statement.Quote(column)
+	}
+	return column
+}
@@ -0,0 +1,56 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestDBQuerySearch(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	q := url.Values{}
+	q.Add(QuerySearch, "title,content:foo")
+	q.Add(QuerySort, "-"+QuerySearchRank)
+	sql := dq.ToSQL((&Article{}).GetSchema(), q)
+	if !strings.Contains(sql, `("a"."title" ILIKE '%foo%' OR "a"."content" ILIKE '%foo%')`) {
+		t.Errorf("Expected like search, got:\n%v", sql)
+	}
+	if strings.Contains(sql, QuerySearchRank) {
+		t.Errorf("Expected no search rank for like search, got:\n%v", sql)
+	}
+
+	tests := []struct {
+		strategy SearchStrategy
+		expected []string
+	}{
+		{PostgresFullTextSearch{}, []string{
+			`ts_rank(to_tsvector('simple', coalesce("a"."title", '') || ' ' || coalesce("a"."content", '')), plainto_tsquery('simple', 'foo')) AS "$search_rank"`,
+			`AND to_tsvector('simple', coalesce("a"."title", '') || ' ' || coalesce("a"."content", '')) @@ plainto_tsquery('simple', 'foo')`,
+			`ORDER BY "$search_rank" DESC`,
+		}},
+		{PostgresFullTextSearch{Config: "english", Column: "a.search_vector"}, []string{
+			`AND "a"."search_vector" @@ plainto_tsquery('english', 'foo')`,
+		}},
+		{MySQLFullTextSearch{}, []string{
+			`MATCH ("a"."title", "a"."content") AGAINST ('foo' IN NATURAL LANGUAGE MODE) AS "$search_rank"`,
+		}},
+		{SQLiteFTS5Search{Table: "articles_fts", Key: "a.rowid"}, []string{
+			`AND "a"."rowid" IN (SELECT rowid FROM "articles_fts" WHERE "articles_fts" MATCH '{title content} : "foo"')`,
+			`(SELECT -rank FROM "articles_fts" WHERE "articles_fts" MATCH '{title content} : "foo"' AND rowid = "a"."rowid") AS "$search_rank"`,
+		}},
+	}
+	for _, tt := range tests {
+		schema := (&Article{}).GetSchema()
+		schema["searchStrategy"] = tt.strategy
+		sql := dq.ToSQL(schema, q)
+		for _, expected := range tt.expected {
+			if !strings.Contains(sql, expected) {
+				t.Errorf("Expected [%v] in:\n%v", expected, sql)
+			}
+		}
+	}
+}
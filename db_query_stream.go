@@ -0,0 +1,132 @@
+package grest
+
+import (
+	"context"
+	"net/url"
+
+	"gorm.io/gorm"
+)
+
+// FindEach finds all records matching given conditions conds from model and query params, each record is passed to fn, see DBQuery.FindEach
+func FindEach(db *gorm.DB, model ModelInterface, query url.Values, fn func(row map[string]any) error) error {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+		Query:  query,
+	}
+	return q.FindEach(q.Schema, query, fn)
+}
+
+// FindEach finds all records matching given conditions conds from schema and query params,
+// the rows are streamed from db and passed to fn one by one instead of loaded to memory (ex: export with QueryDisablePagination)
+//
+// the array fields (QueryInclude) are queried for every QueryIncludeBatchSize rows while the rows are still streamed on another connection,
+// but if the DB is a transaction (including QueryLimits.StatementTimeout) the rows are loaded before the array fields are queried,
+// because the connection of the transaction can not run another query while streaming the rows.
+// the iteration is stopped when fn returns error or the context of the DB (see gorm.DB.WithContext) is done, and the error is returned as is
+func (q *DBQuery) FindEach(schema map[string]any, query url.Values, fn func(row map[string]any) error) error {
+	return q.read(func() error {
+		return q.findEach(schema, query, fn)
+	})
+}
+
+// findEach streams all records matching given conditions conds from schema and query params to fn, see FindEach
+func (q *DBQuery) findEach(schema map[string]any, query url.Values, fn func(row map[string]any) error) error {
+	// the cursor pagination is limited by the page size, so the rows are loaded using Find
+	if q.IsCursorPagination(query) {
+		rows, err := q.find(schema, query)
+		if err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	keys, _ := q.includePaths(schema, query.Get(QueryInclude))
+	isBuffered := len(keys) > 0 && q.isTransaction()
+
+	db, err := q.Prepare(nil, schema, query)
+	if err != nil {
+		return q.toError(err)
+	}
+	db = q.SetSelect(db, schema, query)
+	db = q.SetOrder(db, schema, query)
+	db = q.SetPagination(db, query)
+	rows, err := db.Rows()
+	if err != nil {
+		return q.toError(err)
+	}
+	defer rows.Close()
+
+	ctx := db.Statement.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	isSortedBySearchRank := q.isSortedBySearchRank(schema, query)
+	batchSize := QueryIncludeBatchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+	batch := make([]map[string]any, 0, batchSize)
+	flush := func(chunk []map[string]any) error {
+		if len(chunk) == 0 {
+			return nil
+		}
+		if isSortedBySearchRank {
+			for _, row := range chunk {
+				delete(row, QuerySearchRank)
+			}
+		}
+		included, err := q.includeArray(schema, query, q.fixDataType(schema, chunk))
+		if err != nil {
+			return err
+		}
+		for _, row := range included {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := fn(row); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for rows.Next() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		row := map[string]any{}
+		if err := db.ScanRows(rows, &row); err != nil {
+			return q.toError(err)
+		}
+		batch = append(batch, row)
+		if !isBuffered && len(batch) >= batchSize {
+			if err := flush(batch); err != nil {
+				return err
+			}
+			batch = make([]map[string]any, 0, batchSize)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return q.toError(err)
+	}
+
+	// the connection is released before querying the array fields of the loaded rows
+	rows.Close()
+	for len(batch) > batchSize {
+		if err := flush(batch[:batchSize]); err != nil {
+			return err
+		}
+		batch = batch[batchSize:]
+	}
+	return flush(batch)
+}
@@ -0,0 +1,122 @@
+package grest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"gorm.io/gorm"
+)
+
+func TestDBQueryFindEach(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	defaultBatchSize := QueryIncludeBatchSize
+	defer func() { QueryIncludeBatchSize = defaultBatchSize }()
+	QueryIncludeBatchSize = 2
+
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "is_active"}).
+			AddRow("a1", "1").
+			AddRow("a2", "0").
+			AddRow("a3", "1"))
+	mock.ExpectQuery(`"ac"\."article_id" IN \(\$\d,\$\d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}).AddRow("c1", "a1"))
+	mock.ExpectQuery(`"ac"\."article_id" IN \(\$\d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}).AddRow("c2", "a3"))
+
+	result := []string{}
+	query := url.Values{QueryInclude: {"categories"}, QueryDisablePagination: {"true"}}
+	err = FindEach(db, &Article{}, query, func(row map[string]any) error {
+		categories, _ := row["categories"].([]map[string]any)
+		result = append(result, fmt.Sprintf("%v:%v:%v", row["id"], row["is_active"], len(categories)))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+	if fmt.Sprint(result) != "[a1:true:1 a2:false:0 a3:true:1]" {
+		t.Errorf("Expected streamed rows with fixed data type and included array fields, got [%v]", result)
+	}
+
+	// the iteration is stopped by the error of fn
+	errStop := errors.New("stop")
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1").AddRow("a2").AddRow("a3"))
+	count := 0
+	err = FindEach(db, &Article{}, url.Values{}, func(row map[string]any) error {
+		count++
+		return errStop
+	})
+	if err != errStop || count != 1 {
+		t.Errorf("Expected stopped after the first row with the fn error, got [%v] after [%v] rows", err, count)
+	}
+
+	// the iteration is stopped by the canceled context
+	ctx, cancel := context.WithCancel(context.Background())
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1").AddRow("a2").AddRow("a3"))
+	count = 0
+	err = FindEach(db.WithContext(ctx), &Article{}, url.Values{}, func(row map[string]any) error {
+		count++
+		cancel()
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) || count != 1 {
+		t.Errorf("Expected stopped after the first row with canceled context, got [%v] after [%v] rows", err, count)
+	}
+
+	// the rows are loaded before the array fields are queried in a transaction (including the statement timeout)
+	articleRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id"}).AddRow("a1").AddRow("a2").AddRow("a3")
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`SET LOCAL statement_timeout = 1000`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).WillReturnRows(articleRows())
+	mock.ExpectQuery(`"ac"\."article_id" IN \(\$\d,\$\d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}).AddRow("c1", "a1"))
+	mock.ExpectQuery(`"ac"\."article_id" IN \(\$\d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}).AddRow("c2", "a3"))
+	mock.ExpectCommit()
+	dq := &DBQuery{DB: db, Limits: &QueryLimits{IsAllowDisablePagination: true, StatementTimeout: time.Second}}
+	result = []string{}
+	err = dq.FindEach((&Article{}).GetSchema(), query, func(row map[string]any) error {
+		categories, _ := row["categories"].([]map[string]any)
+		result = append(result, fmt.Sprintf("%v:%v", row["id"], len(categories)))
+		return nil
+	})
+	if err != nil || fmt.Sprint(result) != "[a1:1 a2:0 a3:1]" {
+		t.Errorf("Expected the rows with included array fields using the statement timeout, got [%v] [%v]", err, result)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).WillReturnRows(articleRows())
+	mock.ExpectQuery(`"ac"\."article_id" IN \(\$\d,\$\d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}))
+	mock.ExpectQuery(`"ac"\."article_id" IN \(\$\d\)`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}))
+	mock.ExpectCommit()
+	count = 0
+	err = db.Transaction(func(tx *gorm.DB) error {
+		return FindEach(tx, &Article{}, query, func(row map[string]any) error {
+			count++
+			return nil
+		})
+	})
+	if err != nil || count != 3 {
+		t.Errorf("Expected the rows with included array fields in a transaction, got [%v] after [%v] rows", err, count)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+}
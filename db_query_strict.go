@@ -0,0 +1,320 @@
+package grest
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// strictQueryError is the invalid query params value with the validation tag used to translate the error message
+type strictQueryError struct {
+	param string
+	value string
+	tag   string
+}
+
+// checkStrictQuery rejects unknown fields, unknown operators and invalid values (based on the field type) of the query params,
+// the errors are translated by the Validator (same as Create, Update & Patch) with the detail keyed by the query params
+func (q *DBQuery) checkStrictQuery(schema map[string]any, query url.Values) error {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	keys := []string{}
+	for key := range query {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	errs := []strictQueryError{}
+	for _, key := range keys {
+		val := query.Get(key)
+		switch key {
+		case QueryLimit, QueryOffset, QueryPage:
+			if !q.validator().IsValid(val, "number") {
+				errs = append(errs, strictQueryError{key, val, "number"})
+			}
+		case QueryDisablePagination:
+			if !q.validator().IsValid(val, "boolean") {
+				errs = append(errs, strictQueryError{key, val, "boolean"})
+			}
+		case QueryAfter, QueryBefore:
+			// the cursor is validated by SetCursor
+		case QuerySort:
+			for _, s := range strings.Split(val, ",") {
+				s = strings.TrimSuffix(strings.TrimPrefix(s, "-"), ":i")
+				if s != QuerySearchRank && s != "count_all" && !q.isStrictAgg(fields, s) && !q.isStrictField(fields, s) {
+					errs = append(errs, q.unknownFieldError(schema, key, s))
+				}
+			}
+		case QuerySelect, QueryGroup, QueryExclude:
+			for _, s := range strings.Split(val, ",") {
+				if (key != QuerySelect || !q.isStrictAgg(fields, s)) && fields[s] == nil && arrayFields[s] == nil {
+					errs = append(errs, q.unknownFieldError(schema, key, s))
+				}
+			}
+		case QueryInclude:
+			for _, s := range strings.Split(val, ",") {
+				errs = append(errs, q.checkStrictInclude(schema, key, s)...)
+			}
+		case QuerySearch:
+			searchKey, _, _ := strings.Cut(val, ":")
+			searchKey, _, _ = strings.Cut(searchKey, "=")
+			for _, s := range strings.Split(searchKey, ",") {
+				if fields[s] == nil {
+					errs = append(errs, q.unknownFieldError(schema, key, s))
+				}
+			}
+		case QueryOr:
+			for _, ov := range query[key] {
+				orQueries := strings.Split(ov, QueryOrDelimiter)
+				if strings.Contains(ov, "||") {
+					orQueries = strings.Split(ov, "||")
+				}
+				for _, orQuery := range orQueries {
+					orQ, orVal, found := strings.Cut(orQuery, ":")
+					if !found {
+						orQ, orVal, _ = strings.Cut(orQuery, "=")
+					}
+					errs = append(errs, q.checkStrictFilter(schema, key, orQ, orVal)...)
+				}
+			}
+		case QueryFilter:
+			node, err := ParseFilter(val)
+			if err == nil {
+				errs = append(errs, q.checkStrictFilterNode(schema, node)...)
+			}
+		default:
+			errs = append(errs, q.checkStrictFilter(schema, key, key, val)...)
+		}
+	}
+	return q.strictQueryErrorsToError(errs)
+}
+
+// checkStrictFilterNode checks the comparisons of the QueryFilter expression
+func (q *DBQuery) checkStrictFilterNode(schema map[string]any, node *FilterNode) []strictQueryError {
+	if node.Op != "" {
+		errs := []strictQueryError{}
+		for _, child := range node.Children {
+			errs = append(errs, q.checkStrictFilterNode(schema, child)...)
+		}
+		return errs
+	}
+	val := ""
+	switch v := node.Value.(type) {
+	case nil:
+		val = "null"
+	case string:
+		val = v
+	case []string:
+		val = strings.Join(v, ",")
+	}
+	return q.checkStrictFilter(schema, QueryFilter, node.Field+"."+*filterOperators[node.Operator], val)
+}
+
+// checkStrictInclude checks the array field keys of the include path (ex: orders.items.product_options) recursively
+func (q *DBQuery) checkStrictInclude(schema map[string]any, param, path string) []strictQueryError {
+	if path == "all" {
+		return nil
+	}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	k, child := q.splitIncludePath(arrayFields, path)
+	if k == "" {
+		arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
+		key, _, _ := strings.Cut(path, ".")
+		return []strictQueryError{{param, key, "oneof=" + strings.Join(append([]string{"all"}, arrayFieldOrder...), " ")}}
+	}
+	if child == "" {
+		return nil
+	}
+	arraySchema, _ := arrayFields[k]["schema"].(map[string]any)
+	return q.checkStrictInclude(arraySchema, param, child)
+}
+
+// checkStrictFilter checks the field, the operator and the value of the filter key val of the query params (param)
+func (q *DBQuery) checkStrictFilter(schema map[string]any, param, key, val string) []strictQueryError {
+	key, _ = url.QueryUnescape(key)
+	if strings.HasPrefix(key, QueryDbField+".") || q.isScopedQueryParam(key) {
+		return nil
+	}
+
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for k, arrayField := range arrayFields {
+		for _, sep := range []string{".0.", ".*."} {
+			if childKey, ok := strings.CutPrefix(key, k+sep); ok {
+				arraySchema, _ := arrayField["schema"].(map[string]any)
+				return q.checkStrictFilter(arraySchema, param, childKey, val)
+			}
+		}
+	}
+
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	if cond := q.qsToHavingCond(key, val, fields); cond["column1"] != nil {
+		return nil
+	}
+
+	fieldKey, optKey := q.qsToFieldKey(schema, key)
+	if fieldKey == "" {
+		if i := strings.LastIndex(key, "."); i >= 0 && strings.HasPrefix(key[i+1:], "$") {
+			if fk, _ := q.qsToFieldKey(schema, key[:i]); fk != "" {
+				return []strictQueryError{{param, key[i+1:], "oneof=" + strings.Join(q.strictOperators(), " ")}}
+			}
+		}
+		return []strictQueryError{q.unknownFieldError(schema, param, key)}
+	}
+
+	// the value of casted or json field is not validated by the field type
+	if strings.TrimSuffix(key, "."+optKey) != fieldKey || strings.HasPrefix(val, QueryField+":") {
+		return nil
+	}
+	if optKey == QueryOptLike || optKey == QueryOptNotLike || optKey == QueryOptInsensitiveLike || optKey == QueryOptInsensitiveNotLike {
+		return nil
+	}
+	fieldType, _ := fields[fieldKey]["type"].(string)
+	vals := []string{val}
+	if optKey == QueryOptIn || optKey == QueryOptNotIn {
+		vals = strings.Split(val, ",")
+	}
+	errs := []strictQueryError{}
+	for _, v := range vals {
+		v, _ = url.QueryUnescape(v)
+		if _, tag := q.coerceValue(fieldType, v); tag != "" {
+			errs = append(errs, strictQueryError{param, v, tag})
+		}
+	}
+	return errs
+}
+
+// coerceValue converts the query params value to the db value based on the field type,
+// the validation tag is returned if the value is invalid
+func (q *DBQuery) coerceValue(fieldType, val string) (any, string) {
+	if strings.ToLower(val) == "null" {
+		return val, ""
+	}
+	var n interface {
+		json.Unmarshaler
+		driver.Valuer
+	}
+	tag := ""
+	switch fieldType {
+	case "NullInt64":
+		n, tag = &NullInt64{}, "number"
+	case "NullFloat64":
+		n, tag = &NullFloat64{}, "numeric"
+	case "NullBool":
+		n, tag = &NullBool{}, "boolean"
+	case "NullDate":
+		n, tag = &NullDate{}, "datetime=2006-01-02"
+	case "NullDateTime":
+		n, tag = &NullDateTime{}, "datetime=2006-01-02T15:04:05Z07:00"
+	case "NullUUID":
+		if !q.validator().IsValid(val, "uuid") {
+			return val, "uuid"
+		}
+		return strings.ToLower(val), ""
+	default:
+		return val, ""
+	}
+	data, _ := json.Marshal(val)
+	n.UnmarshalJSON(data)
+	v, err := n.Value()
+	if err != nil || v == nil {
+		return val, tag
+	}
+	return v, ""
+}
+
+// coerceCondValue converts the condition value based on column1type, the value is unchanged if it is invalid
+func (q *DBQuery) coerceCondValue(cond map[string]any) any {
+	value := cond["value"]
+	cast, _ := cond["cast"].(string)
+	operator, _ := cond["operator"].(string)
+	fieldType, _ := cond["column1type"].(string)
+	if cast != "" || cond["column1jsonKey"] != nil || strings.Contains(strings.ToUpper(operator), "LIKE") {
+		return value
+	}
+	vals, isList := value.([]string)
+	if str, ok := value.(string); ok && strings.Contains(strings.ToUpper(operator), "IN") {
+		vals, isList = strings.Split(str, ","), true
+	}
+	if isList {
+		coerced := []any{}
+		for _, v := range vals {
+			cv, _ := q.coerceValue(fieldType, v)
+			coerced = append(coerced, cv)
+		}
+		return coerced
+	}
+	if str, ok := value.(string); ok {
+		if cv, tag := q.coerceValue(fieldType, str); tag == "" {
+			return cv
+		}
+	}
+	return value
+}
+
+// isStrictField returns true if the key is a field or a sub key of json field
+func (q *DBQuery) isStrictField(fields map[string]map[string]any, key string) bool {
+	if fields[key] != nil {
+		return true
+	}
+	for k, v := range fields {
+		fType, _ := v["type"].(string)
+		if strings.HasPrefix(key, k+".") && strings.Contains(strings.ToLower(fType), "json") {
+			return true
+		}
+	}
+	return false
+}
+
+// isStrictAgg returns true if the key is an aggregate of a field (ex: $sum:sold) or $count
+func (q *DBQuery) isStrictAgg(fields map[string]map[string]any, key string) bool {
+	aggKey, fieldKey, _ := strings.Cut(key, QueryCast)
+	if q.qsToAggFuncSQL(aggKey) == "" {
+		return false
+	}
+	return (fieldKey == "" && aggKey == QueryCount) || fields[fieldKey] != nil
+}
+
+// strictOperators returns all filter operators
+func (q *DBQuery) strictOperators() []string {
+	return []string{
+		QueryOptEqual, QueryOptNotEqual, QueryOptGreaterThan, QueryOptGreaterThanOrEqual, QueryOptLowerThan, QueryOptLowerThanOrEqual,
+		QueryOptLike, QueryOptNotLike, QueryOptInsensitiveLike, QueryOptInsensitiveNotLike, QueryOptIn, QueryOptNotIn,
+	}
+}
+
+// unknownFieldError returns the error of unknown field, validated as one of the schema fields
+func (q *DBQuery) unknownFieldError(schema map[string]any, param, key string) strictQueryError {
+	fieldOrder, _ := schema["fieldOrder"].([]string)
+	return strictQueryError{param, key, "oneof=" + strings.Join(fieldOrder, " ")}
+}
+
+// strictQueryErrorsToError translates the errors using the Validator, nil is returned if there is no error
+func (q *DBQuery) strictQueryErrorsToError(errs []strictQueryError) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	structFields := []reflect.StructField{}
+	for i, e := range errs {
+		structFields = append(structFields, reflect.StructField{
+			Name: "F" + strconv.Itoa(i),
+			Type: reflect.TypeOf(""),
+			Tag:  reflect.StructTag(`json:` + strconv.Quote(e.param) + ` validate:` + strconv.Quote(e.tag)),
+		})
+	}
+	st := reflect.New(reflect.StructOf(structFields)).Elem()
+	for i, e := range errs {
+		st.Field(i).SetString(e.value)
+	}
+	if err := q.validator().ValidateStruct(st.Interface(), q.Lang); err != nil {
+		return err
+	}
+
+	// the value is invalid for the field type but valid for the validation tag (ex: out of range integer)
+	msg := "The " + errs[0].param + " is invalid."
+	return NewError(http.StatusBadRequest, msg, map[string]any{errs[0].param: map[string]any{errs[0].tag: msg}})
+}
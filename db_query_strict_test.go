@@ -0,0 +1,102 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDBQueryStrict(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db, IsStrict: true}
+	schema := (&Article{}).GetSchema()
+
+	testCases := []struct {
+		key, val, expectedTag string
+	}{
+		{"id", "3f6f0c5e-8a1b-4c1e-9b1a-2f7a0c3d4e5f", ""},
+		{"id", "abc", "uuid"},
+		{"total_review.$gt", "abc", "numeric"},
+		{"total_review.$in", "1,2,x", "numeric"},
+		{"is_active", "yes", "boolean"},
+		{"created_at.$gte", "2024-01-01T00:00:00Z", ""},
+		{"created_at.$gte", "2024-13-01", "datetime"},
+		{"created_at:date.$gte", "2024-01-01", ""},
+		{"title.$like", "abc%", ""},
+		{"detail.foo.bar", "baz", ""},
+		{"unknown", "1", "oneof"},
+		{"title.$unknown", "1", "oneof"},
+		{"categories.0.unknown", "1", "oneof"},
+		{"id", "$field:author.id", ""},
+		{"$sum:total_review.$gt", "1", ""},
+		{QuerySort, "-title,$search_rank", ""},
+		{QuerySort, "unknown", "oneof"},
+		{QuerySelect, "id,$count:id", ""},
+		{QueryInclude, "categories", ""},
+		{QueryInclude, "phones", "oneof"},
+		{QueryInclude, "categories.phones", "oneof"},
+		{"categories.*." + QuerySort, "-code", ""},
+		{QueryPage, "x", "number"},
+		{QueryOr, "title=a|is_active=x", "boolean"},
+		{QueryFilter, "id eq 'abc'", "uuid"},
+	}
+	for _, tc := range testCases {
+		err := dq.checkStrictQuery(schema, url.Values{tc.key: {tc.val}})
+		if tc.expectedTag == "" {
+			if err != nil {
+				t.Errorf("Expected %v=%v is valid, got [%v]", tc.key, tc.val, err)
+			}
+			continue
+		}
+		e, ok := err.(*Error)
+		if !ok || e.Code != 400 {
+			t.Errorf("Expected %v=%v is invalid with 400 error, got [%v]", tc.key, tc.val, err)
+			continue
+		}
+		detail, _ := e.Detail.(map[string]any)
+		tags, _ := detail[tc.key].(map[string]any)
+		isTagExists := false
+		for tag := range tags {
+			isTagExists = isTagExists || strings.HasPrefix(tag, tc.expectedTag)
+		}
+		if !isTagExists {
+			t.Errorf("Expected %v=%v is invalid by %v, got [%v]", tc.key, tc.val, tc.expectedTag, e.Detail)
+		}
+	}
+
+	_, err = dq.Find(schema, url.Values{"total_review.$gt": {"abc"}})
+	if e, ok := err.(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error, got [%v]", err)
+	}
+
+	v, tag := dq.coerceValue("NullInt64", "18")
+	if v != int64(18) || tag != "" {
+		t.Errorf("Expected [18], got [%v] [%v]", v, tag)
+	}
+	v, _ = dq.coerceValue("NullDateTime", "2024-01-02T03:04:05Z")
+	if tm, ok := v.(time.Time); !ok || tm.Year() != 2024 {
+		t.Errorf("Expected time.Time, got [%v]", v)
+	}
+
+	sql := dq.ToSQL(schema, url.Values{"is_active": {"true"}, "total_review.$in": {"1,2"}})
+	for _, expected := range []string{`"a"."is_active"='1'`, `coalesce(tr.total_review,0) IN (1,2)`} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected [%v] in:\n%v", expected, sql)
+		}
+	}
+
+	// the table schema of the relation is not checked against the client query params of the parent
+	sq := &DBQuery{DB: db, IsStrict: true, Query: url.Values{"title": {"foo"}}}
+	sql = sq.ToSQL(schema)
+	if !strings.Contains(sql, `LEFT JOIN ( SELECT`) || !strings.Contains(sql, `FROM "reviews"`) || sq.Err != nil {
+		t.Errorf("Expected the sub query of the relation is joined, got [%v]:\n%v", sq.Err, sql)
+	}
+	sq.Query = url.Values{"unknown": {"foo"}}
+	if sql = sq.ToSQL(schema); sql != "" || sq.Err == nil {
+		t.Errorf("Expected the error of the invalid query params, got [%v]:\n%v", sq.Err, sql)
+	}
+}
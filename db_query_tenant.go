@@ -0,0 +1,81 @@
+package grest
+
+import (
+	"context"
+	"slices"
+	"strings"
+)
+
+// QueryTenantColumn is the tenant column of the tenant scoped tables, see DBQuery.TenantID
+var QueryTenantColumn = "tenant_id"
+
+// QueryTenantGlobalTables is the tables shared by all tenants (without the tenant column),
+// used for the joined tables which have no model, the tenant global model is declared by implementing IsTenantGlobal() bool which returns true
+var QueryTenantGlobalTables = []string{}
+
+// tenantContextKey is the context key of the tenant id, see ContextWithTenant
+type tenantContextKey struct{}
+
+// ContextWithTenant returns a copy of ctx with the tenant id, used by DBQuery when DBQuery.TenantID is not setted
+//
+//	db = db.WithContext(grest.ContextWithTenant(c.Context(), companyID))
+func ContextWithTenant(ctx context.Context, tenantID any) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenantID)
+}
+
+// TenantFromContext returns the tenant id of ctx, nil if it is not setted
+func TenantFromContext(ctx context.Context) any {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Value(tenantContextKey{})
+}
+
+// tenant returns the tenant id of the query, DBQuery.TenantID or the tenant id of the db context
+func (q *DBQuery) tenant() any {
+	if q.TenantID != nil {
+		return q.TenantID
+	}
+	if q.DB == nil || q.DB.Statement == nil {
+		return nil
+	}
+	return TenantFromContext(q.DB.Statement.Context)
+}
+
+// isTenantTable returns true if the table is scoped by the tenant
+func (q *DBQuery) isTenantTable(tableName string) bool {
+	return q.tenant() != nil && tableName != "" && !slices.Contains(QueryTenantGlobalTables, tableName)
+}
+
+// isTenantSchema returns true if the main table of the schema is scoped by the tenant
+func (q *DBQuery) isTenantSchema(schema map[string]any) bool {
+	if isGlobal, _ := schema["isTenantGlobal"].(bool); isGlobal {
+		return false
+	}
+	tableName, _ := schema["tableName"].(string)
+	return q.isTenantTable(tableName)
+}
+
+// tenantCond returns the tenant condition of the table alias, nil if the table is not scoped by the tenant
+//
+// the table from sub query (table schema) is not scoped, because the sub query has its own tenant condition,
+// the raw sub query is not scoped too, the tenant condition must be written in the raw sub query
+func (q *DBQuery) tenantCond(tableName, tableAliasName string) map[string]any {
+	if !q.isTenantTable(tableName) || strings.Contains(tableName, " ") {
+		return nil
+	}
+	if tableAliasName == "" {
+		tableAliasName = tableName
+	}
+	return map[string]any{"column1": tableAliasName + "." + QueryTenantColumn, "operator": "=", "value": q.tenant()}
+}
+
+// schemaTenantCond returns the tenant condition of the main table of the schema, see tenantCond
+func (q *DBQuery) schemaTenantCond(schema map[string]any) map[string]any {
+	if tableSchema, _ := schema["tableSchema"].(map[string]any); len(tableSchema) > 0 || !q.isTenantSchema(schema) {
+		return nil
+	}
+	tableName, _ := schema["tableName"].(string)
+	tableAliasName, _ := schema["tableAliasName"].(string)
+	return q.tenantCond(tableName, tableAliasName)
+}
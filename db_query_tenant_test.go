@@ -0,0 +1,86 @@
+package grest
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type tenantGlobalCategory struct {
+	Category
+}
+
+func (m *tenantGlobalCategory) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+func (m *tenantGlobalCategory) IsTenantGlobal() bool {
+	return true
+}
+
+func TestDBQueryTenant(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// the main table, the joined table and the joined sub query are scoped by the tenant
+	dq := &DBQuery{DB: db, TenantID: "t1"}
+	sql := dq.ToSQL((&Article{}).GetSchema(), url.Values{})
+	for _, expected := range []string{
+		`LEFT JOIN "users" AS "u" ON "u"."id"="a"."author_id" AND "u"."tenant_id"='t1'`,
+		`FROM "reviews" AS "r" WHERE "r"."tenant_id"='t1'`,
+		`WHERE "a"."tenant_id"='t1' AND "a"."deleted_at" IS NULL`,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected [%v] in [%v]", expected, sql)
+		}
+	}
+
+	// the tenant id from the context, and the tenant global tables are not scoped
+	defaultGlobalTables := QueryTenantGlobalTables
+	QueryTenantGlobalTables = []string{"users"}
+	dq = &DBQuery{DB: db.WithContext(ContextWithTenant(context.Background(), "t2"))}
+	sql = dq.ToSQL((&tenantGlobalCategory{}).GetSchema(), url.Values{})
+	QueryTenantGlobalTables = defaultGlobalTables
+	if strings.Contains(sql, `"u"."tenant_id"`) || strings.Contains(sql, `"c"."tenant_id"`) {
+		t.Errorf("Expected the tenant global tables are not scoped, got [%v]", sql)
+	}
+	if !strings.Contains(sql, `"ac"."tenant_id"='t2'`) {
+		t.Errorf("Expected the joined table is scoped by the context tenant, got [%v]", sql)
+	}
+	if sql := (&DBQuery{DB: db}).ToSQL((&Article{}).GetSchema(), url.Values{}); strings.Contains(sql, "tenant_id") {
+		t.Errorf("Expected no tenant condition without tenant, got [%v]", sql)
+	}
+
+	// the array fields query is scoped by the tenant
+	dq = &DBQuery{DB: db, TenantID: "t1"}
+	mock.ExpectQuery(`"u"\."tenant_id"=\$\d.*WHERE "a"\."tenant_id"=\$\d`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("a1"))
+	mock.ExpectQuery(`"ac"\."tenant_id"=\$\d.*WHERE "c"\."tenant_id"=\$\d`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}).AddRow("c1", "a1"))
+	_, err = dq.Find((&Article{}).GetSchema(), url.Values{QueryInclude: {"categories"}})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// the writes are scoped by the tenant
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "deleted_at"=$1 WHERE "id" = $2 AND "tenant_id" = $3 AND "deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), "c1", "t1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if err := dq.Delete((&Category{}).GetSchema(), "c1"); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+
+	if key := (&DBQuery{DB: db, Cache: &Cache{}, Model: &cachedArticle{}, TenantID: "t1"}).cacheKey((&cachedArticle{}).GetSchema(), url.Values{}); !strings.HasSuffix(key, "@t1") {
+		t.Errorf("Expected the cache key is scoped by the tenant, got [%v]", key)
+	}
+}
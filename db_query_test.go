@@ -2,8 +2,10 @@ package grest
 
 import (
 	"database/sql/driver"
+	"fmt"
 	"net/url"
 	"regexp"
+	"strings"
 	"testing"
 
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
@@ -72,3 +74,221 @@ func TestDBQueryGeneral(t *testing.T) {
 	q.Add("detail.foo.bar.$like", "baz")
 	Find(db, &Article{}, q)
 }
+
+func TestDBQueryFindWithPagination(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "articles" AS "a" WHERE "a"."deleted_at" IS NULL`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(25))
+	mock.ExpectQuery(regexp.QuoteMeta(`ORDER BY "a"."created_at" DESC LIMIT 10 OFFSET 10`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+
+	q := url.Values{}
+	q.Add(QueryPage, "2")
+	rows, info, err := FindWithPagination(db, &Article{}, q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if len(rows) != 1 || info.Page != 2 || info.PerPage != 10 || info.TotalPages != 3 || info.TotalRows != 25 {
+		t.Errorf("Expected 1 row & page 2 of 3 from 25 rows, got [%v] [%+v]", rows, info)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM (SELECT "a"."author_id" AS "author.id", SUM(a.id) AS "sum_id"`) + ".*" + regexp.QuoteMeta(`GROUP BY "a"."author_id") AS "count_query"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(4))
+	q = url.Values{}
+	q.Add(QueryGroup, "author.id")
+	q.Add(QuerySelect, "author.id,$sum:id")
+	q.Add(QueryLimit, "0")
+	rows, info, err = FindWithPagination(db, &Article{}, q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if len(rows) != 0 || info.TotalRows != 4 || info.PerPage != 0 {
+		t.Errorf("Expected count only query with 4 groups, got [%v] [%+v]", rows, info)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+}
+
+func TestDBQueryArrayFieldFilter(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	for _, sep := range []string{".0.", ".*."} {
+		q := url.Values{}
+		q.Add("categories"+sep+"code.$like", "foo")
+		a := &Article{}
+		dq := &DBQuery{DB: db, Model: a, Schema: a.GetSchema(), Query: q}
+		result := dq.ToSQL(dq.Schema, q)
+		for _, expected := range []string{
+			`WHERE "a"."deleted_at" IS NULL AND EXISTS (SELECT 1 FROM "categories" AS "c" INNER JOIN "articles_categories" AS "ac" ON "ac"."category_id"="c"."id"`,
+			`"c"."is_active"='1'`,
+			`"c"."code" LIKE '%foo%'`,
+			`"ac"."article_id"="a"."id")`,
+		} {
+			if !strings.Contains(result, expected) {
+				t.Errorf("Expected SQL contains:\n%v\nGot:\n%v", expected, result)
+			}
+		}
+	}
+
+	// the invalid filter of the array field is returned instead of compiled into the parent where
+	for _, tc := range []struct {
+		schema map[string]any
+		query  url.Values
+	}{
+		{(&Article{}).GetSchema(), url.Values{"categories.0." + QueryFilter: {"code eq"}}},
+		{(&Article{}).GetSchema(), url.Values{QueryOr: {"title=foo|categories.0." + QueryFilter + "=code eq"}}},
+		{orderSchemaWithOptions(), url.Values{"items.0.options.0." + QueryFilter: {"name eq"}}},
+	} {
+		dq := &DBQuery{DB: db}
+		if result := dq.ToSQL(tc.schema, tc.query); result != "" || dq.Err == nil {
+			t.Errorf("Expected the error of the array field filter %v, got [%v]:\n%v", tc.query, dq.Err, result)
+		}
+	}
+}
+
+func TestDBQueryIncludeArrayBatch(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "articles" AS "a"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).
+			AddRow("a1", "foo").
+			AddRow("a2", "bar").
+			AddRow("a3", "baz"))
+	mock.ExpectQuery(regexp.QuoteMeta(`"ac"."article_id" IN ($`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "code", "article.id"}).
+			AddRow("c1", "x", "a1").
+			AddRow("c2", "y", "a2").
+			AddRow("c3", "z", "a1"))
+
+	q := url.Values{}
+	q.Add(QueryInclude, "categories")
+	rows, err := Find(db, &Article{}, q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Expectations were not met : [%v]", err)
+	}
+	expected := map[string][]string{"a1": {"c1", "c3"}, "a2": {"c2"}, "a3": {}}
+	for _, row := range rows {
+		categories, _ := row["categories"].([]map[string]any)
+		ids := []string{}
+		for _, c := range categories {
+			if _, ok := c["article.id"]; ok {
+				t.Errorf("Expected hidden link field to be removed, got [%v]", c)
+			}
+			ids = append(ids, fmt.Sprintf("%v", c["id"]))
+		}
+		if fmt.Sprint(ids) != fmt.Sprint(expected[fmt.Sprintf("%v", row["id"])]) {
+			t.Errorf("Expected categories of [%v] is [%v], got [%v]", row["id"], expected[fmt.Sprintf("%v", row["id"])], ids)
+		}
+	}
+}
+
+func TestDBQueryFirst(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectQuery(regexp.QuoteMeta(`WHERE "a"."deleted_at" IS NULL AND "a"."id"=$1 ORDER BY "a"."created_at" DESC LIMIT 1`)).
+		WithArgs("a1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}).AddRow("a1", "foo"))
+	mock.ExpectQuery(regexp.QuoteMeta(`FROM "categories" AS "c"`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "article.id"}).AddRow("c1", "a1"))
+
+	q := url.Values{}
+	q.Add("id", "a1")
+	row, err := First(db, &Article{}, q)
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if categories, _ := row["categories"].([]map[string]any); row["id"] != "a1" || len(categories) != 1 {
+		t.Errorf("Expected article a1 with 1 category, got [%v]", row)
+	}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`LIMIT 1`)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "title"}))
+	_, err = First(db, &Article{}, q)
+	if e, ok := err.(*Error); !ok || e.Code != 404 {
+		t.Errorf("Expected 404 error, got [%v]", err)
+	}
+}
+
+func TestDBQueryHaving(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	schema := (&Article{}).GetSchema()
+	schema["queryPolicy"] = QueryPolicy{IsAllowField: true}
+
+	q := url.Values{}
+	q.Add(QueryGroup, "author.id")
+	q.Add(QuerySelect, "author.id,$sum:total_review")
+	q.Add(QuerySum+":total_review."+QueryOptGreaterThan, "0")
+	q.Add(QueryAvg+":total_review:int."+QueryOptLowerThanOrEqual, "5")
+	q.Add(QueryMin+":title", "$field:a.content")
+	sql := dq.ToSQL(schema, q)
+	for _, expected := range []string{
+		`GROUP BY "a"."author_id" HAVING`,
+		`SUM(coalesce(tr.total_review,0))>'0'`,
+		`CAST(AVG(coalesce(tr.total_review,0)) AS INT)<='5'`,
+		`MIN(a.title)="a"."content"`,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected [%v] in:\n%v", expected, sql)
+		}
+	}
+	if strings.Contains(sql, "WHERE "+`SUM(`) {
+		t.Errorf("Expected aggregate filter not in where:\n%v", sql)
+	}
+
+	q = url.Values{}
+	q.Add(QueryGroup, "author.id")
+	q.Add(QueryOr, QueryCount+"."+QueryOptGreaterThan+":1|author.id:x")
+	q.Add(QueryOr, "title:foo|content:bar")
+	sql = dq.ToSQL(schema, q)
+	if !strings.Contains(sql, `HAVING (COUNT(*)>'1' OR "a"."author_id"='x')`) {
+		t.Errorf("Expected $or aggregate filter in having, got:\n%v", sql)
+	}
+	if !strings.Contains(sql, `("a"."title"='foo' OR "a"."content"='bar')`) || strings.Contains(sql, `HAVING ("a"."title"`) {
+		t.Errorf("Expected $or filter in where, got:\n%v", sql)
+	}
+}
+
+func TestDBQueryAggregateSort(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	schema := (&Article{}).GetSchema()
+
+	q := url.Values{}
+	q.Add(QueryGroup, "author.id")
+	q.Add(QuerySelect, "author.id,$sum:total_review,$count,$max:title")
+	q.Add(QuerySort, "-$sum:total_review,count_all,$max:title:i,-$min:title:i")
+	sql := dq.ToSQL(schema, q)
+	expected := `ORDER BY "sum_total_review" DESC,"count_all" ASC,LOWER(MAX(a.title)) ASC,LOWER(MIN(a.title)) DESC`
+	if !strings.HasSuffix(sql, expected) {
+		t.Errorf("Expected [%v] in:\n%v", expected, sql)
+	}
+
+	schema["sorts"] = []map[string]any{{"column": "$count", "direction": "desc", "isRequired": true}}
+	q.Set(QuerySort, "author.id")
+	q.Set(QuerySelect, "author.id,$avg:total_review")
+	sql = dq.ToSQL(schema, q)
+	expected = `ORDER BY "a"."author_id" ASC,COUNT(*) DESC`
+	if !strings.HasSuffix(sql, expected) {
+		t.Errorf("Expected [%v] in:\n%v", expected, sql)
+	}
+}
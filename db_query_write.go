@@ -0,0 +1,595 @@
+package grest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// columnNameRegexp match plain column name, used to check if the field is a column of the main table
+var columnNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// QuerySoftDeleteColumn is the soft delete column, the record of the schema which has a field of this main table column is soft deleted by Delete
+// (the column is setted to the current time) instead of deleted, and the soft deleted record is not found by Update, Patch & Delete.
+// the reads are filtered by the model filters (ex: {"column1": "a.deleted_at", "operator": "=", "value": nil})
+var QuerySoftDeleteColumn = "deleted_at"
+
+// Create inserts a new record from model and nested json data, returns the primary key value
+func Create(db *gorm.DB, model ModelInterface, data map[string]any) (any, error) {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+	}
+	return q.Create(q.Schema, data)
+}
+
+// Update updates the record with the primary key id from model and nested json data
+func Update(db *gorm.DB, model ModelInterface, id any, data map[string]any) error {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+	}
+	return q.Update(q.Schema, id, data)
+}
+
+// Patch partially updates the record with the primary key id from model and nested json data
+func Patch(db *gorm.DB, model ModelInterface, id any, data map[string]any) error {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+	}
+	return q.Patch(q.Schema, id, data)
+}
+
+// Delete deletes the record with the primary key id from model
+func Delete(db *gorm.DB, model ModelInterface, id any) error {
+	q := &DBQuery{
+		DB:     db,
+		Model:  model,
+		Schema: GetSchema(model),
+	}
+	return q.Delete(q.Schema, id)
+}
+
+// Create inserts a new record from nested (or flat) json data to the main table of the schema, returns the primary key value
+//
+// the data is mapped through the schema fields to the main table columns (fields with db tag prefixed by the table alias name),
+// the default tag is applied to the empty fields, the data is validated using the validate tag,
+// and the has many array fields are inserted in the same transaction.
+// the uuid primary key is generated if not setted.
+func (q *DBQuery) Create(schema map[string]any, data map[string]any) (any, error) {
+	flat := q.toFlatData(schema, data)
+	pk := q.primaryKey(schema)
+	err := q.validateData(schema, flat, "", false)
+	if err != nil {
+		return nil, err
+	}
+	err = q.transaction(func(tx *gorm.DB) error {
+		return q.insert(tx, schema, flat)
+	})
+	if err != nil {
+		return nil, err
+	}
+	q.markWritten()
+	q.invalidateCache(schema, flat[pk])
+	return flat[pk], nil
+}
+
+// Update updates the record with the primary key id from nested (or flat) json data
+//
+// the data is validated as a whole record (same as Create), but only the setted fields are updated,
+// the setted array fields are replaced by the new array rows in the same transaction.
+func (q *DBQuery) Update(schema map[string]any, id any, data map[string]any) error {
+	return q.update(schema, id, data, false)
+}
+
+// Patch partially updates the record with the primary key id from nested (or flat) json data
+//
+// same as Update, but only the setted fields are validated
+func (q *DBQuery) Patch(schema map[string]any, id any, data map[string]any) error {
+	return q.update(schema, id, data, true)
+}
+
+// Delete deletes the record with the primary key id and its has many array rows in one transaction
+//
+// the record with QuerySoftDeleteColumn is soft deleted with its soft delete array rows, the other array rows are kept
+func (q *DBQuery) Delete(schema map[string]any, id any) error {
+	pk := q.primaryKey(schema)
+	flat := map[string]any{pk: id}
+	softDeleteColumn, softDeleteValue := q.softDelete(schema)
+	err := q.transaction(func(tx *gorm.DB) error {
+		arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+		arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
+		for _, arrayKey := range arrayFieldOrder {
+			arraySchema, _ := arrayFields[arrayKey]["schema"].(map[string]any)
+			if arrayColumn, _ := q.softDelete(arraySchema); softDeleteColumn != "" && arrayColumn == "" {
+				continue
+			}
+			err := q.deleteArrayRows(tx, schema, arrayFields[arrayKey], flat)
+			if err != nil {
+				return err
+			}
+		}
+		whereSQL, args := q.pkToWhereSQL(schema, id)
+		tableName, _ := schema["tableName"].(string)
+		var res *gorm.DB
+		if softDeleteColumn != "" {
+			res = tx.Table(tableName).Where(whereSQL, args...).Update(softDeleteColumn, softDeleteValue)
+		} else {
+			res = tx.Exec("DELETE FROM "+tx.Statement.Quote(tableName)+" WHERE "+whereSQL, args...)
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return NewError(http.StatusNotFound, "Data is not found")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	q.markWritten()
+	q.invalidateCache(schema, id)
+	return nil
+}
+
+// update updates the main table and replace the setted array fields in one transaction
+func (q *DBQuery) update(schema map[string]any, id any, data map[string]any, isPatch bool) error {
+	flat := q.toFlatData(schema, data, true)
+	pk := q.primaryKey(schema)
+	flat[pk] = id
+	err := q.validateData(schema, flat, "", isPatch)
+	if err != nil {
+		return err
+	}
+	err = q.transaction(func(tx *gorm.DB) error {
+		values := q.toColumnValues(schema, flat)
+		delete(values, q.mainColumn(schema, pk))
+		whereSQL, args := q.pkToWhereSQL(schema, id)
+		tableName, _ := schema["tableName"].(string)
+		isFound := false
+		if len(values) > 0 {
+			res := tx.Table(tableName).Where(whereSQL, args...).Updates(values)
+			if res.Error != nil {
+				return res.Error
+			}
+			isFound = res.RowsAffected > 0
+		}
+
+		// the affected rows is 0 if the record is not found or (on mysql) the values are not changed, so the existence is checked separately
+		if !isFound {
+			var total int64
+			err := tx.Table(tableName).Where(whereSQL, args...).Count(&total).Error
+			if err != nil {
+				return err
+			}
+			if total == 0 {
+				return NewError(http.StatusNotFound, "Data is not found")
+			}
+		}
+
+		arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+		arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
+		for _, arrayKey := range arrayFieldOrder {
+			if _, ok := flat[arrayKey]; !ok {
+				continue
+			}
+			err := q.deleteArrayRows(tx, schema, arrayFields[arrayKey], flat)
+			if err != nil {
+				return err
+			}
+			err = q.insertArrayRows(tx, schema, arrayKey, arrayFields[arrayKey], flat)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	q.markWritten()
+	q.invalidateCache(schema, id)
+	return nil
+}
+
+// insert inserts the main table and the has many array fields
+//
+// the primary key generated by the db (ex: auto increment) is read back using RETURNING, so the has many array fields can be linked to it,
+// error is returned if the db does not support RETURNING (ex: mysql), set the primary key value or use uuid primary key instead.
+func (q *DBQuery) insert(tx *gorm.DB, schema map[string]any, flat map[string]any) error {
+	tableName, _ := schema["tableName"].(string)
+	values := q.toColumnValues(schema, flat)
+	if q.isTenantSchema(schema) {
+		values[QueryTenantColumn] = q.tenant()
+	}
+	pk := q.primaryKey(schema)
+	pkColumn := q.mainColumn(schema, pk)
+	db := tx.Table(tableName)
+	isReturning := pkColumn != "" && values[pkColumn] == nil
+	if isReturning {
+		delete(values, pkColumn)
+		db = db.Clauses(clause.Returning{Columns: []clause.Column{{Name: pkColumn}}})
+	}
+	err := db.Create(values).Error
+	if err != nil {
+		return err
+	}
+	if isReturning {
+		if values[pkColumn] == nil {
+			return NewError(http.StatusInternalServerError, "The generated "+pk+" of "+tableName+" is not returned by the database.")
+		}
+		flat[pk] = values[pkColumn]
+	}
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
+	for _, arrayKey := range arrayFieldOrder {
+		err := q.insertArrayRows(tx, schema, arrayKey, arrayFields[arrayKey], flat)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// insertArrayRows inserts the array rows of the has many array field, the link fields are filled from the parent data
+func (q *DBQuery) insertArrayRows(tx *gorm.DB, schema map[string]any, arrayKey string, arrayField map[string]any, flat map[string]any) error {
+	arraySchema, links := q.arrayLinks(arrayField)
+	if arraySchema == nil {
+		return nil
+	}
+	arrayRows, _ := flat[arrayKey].([]map[string]any)
+	if len(arrayRows) == 0 {
+		return nil
+	}
+	linkValues, err := q.linkValues(tx, schema, links, flat)
+	if err != nil {
+		return err
+	}
+	for _, arrayRow := range arrayRows {
+		for k, v := range linkValues {
+			arrayRow[k] = v
+		}
+		err := q.insert(tx, arraySchema, arrayRow)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteArrayRows deletes the array rows of the has many array field linked to the parent data
+func (q *DBQuery) deleteArrayRows(tx *gorm.DB, schema map[string]any, arrayField map[string]any, flat map[string]any) error {
+	arraySchema, links := q.arrayLinks(arrayField)
+	if arraySchema == nil {
+		return nil
+	}
+	linkValues, err := q.linkValues(tx, schema, links, flat)
+	if err != nil {
+		return err
+	}
+	where := []string{}
+	args := []any{}
+	keys := []string{}
+	for k := range linkValues {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		where = append(where, tx.Statement.Quote(q.mainColumn(arraySchema, k))+" = ?")
+		args = append(args, linkValues[k])
+	}
+	if q.isTenantSchema(arraySchema) {
+		where = append(where, tx.Statement.Quote(QueryTenantColumn)+" = ?")
+		args = append(args, q.tenant())
+	}
+	tableName, _ := arraySchema["tableName"].(string)
+	if column, value := q.softDelete(arraySchema); column != "" {
+		where = append(where, tx.Statement.Quote(column)+" IS NULL")
+		return tx.Table(tableName).Where(strings.Join(where, " AND "), args...).Update(column, value).Error
+	}
+	return tx.Exec("DELETE FROM "+tx.Statement.Quote(tableName)+" WHERE "+strings.Join(where, " AND "), args...).Error
+}
+
+// softDelete return the QuerySoftDeleteColumn of the main table with its value (the current time based on the field type),
+// empty column if the schema is not soft deleted
+func (q *DBQuery) softDelete(schema map[string]any) (string, any) {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	for k, field := range fields {
+		if q.mainColumn(schema, k) != QuerySoftDeleteColumn {
+			continue
+		}
+		fieldType, _ := field["type"].(string)
+		if strings.Contains(strings.ToLower(fieldType), "unix") {
+			return QuerySoftDeleteColumn, time.Now().Unix()
+		}
+		return QuerySoftDeleteColumn, time.Now()
+	}
+	return "", nil
+}
+
+// arrayLinks return the array schema and the map of array field key to the parent field key based on the array field filter placeholders
+//
+// nil schema is returned if the array field is not has many (the link fields are not columns of the array main table), so it is not writable
+func (q *DBQuery) arrayLinks(arrayField map[string]any) (map[string]any, map[string]string) {
+	arraySchema, _ := arrayField["schema"].(map[string]any)
+	if arraySchema == nil {
+		return nil, nil
+	}
+	arrayFilter, _ := arrayField["filter"].(string)
+	filterQuery, _ := url.ParseQuery(arrayFilter)
+	links := map[string]string{}
+	for k, vals := range filterQuery {
+		for _, v := range vals {
+			vars := String{}.GetVars(v, "{", "}")
+			if len(vars) == 1 && v == "{"+vars[0]+"}" {
+				if q.mainColumn(arraySchema, k) == "" {
+					return nil, nil
+				}
+				links[k] = vars[0]
+			}
+		}
+	}
+	if len(links) == 0 {
+		return nil, nil
+	}
+	return arraySchema, links
+}
+
+// linkValues return the map of array field key to the parent value, the parent main table is queried if the value is not in the data
+func (q *DBQuery) linkValues(tx *gorm.DB, schema map[string]any, links map[string]string, flat map[string]any) (map[string]any, error) {
+	values := map[string]any{}
+	missing := []string{}
+	for k, parentKey := range links {
+		if v, ok := flat[parentKey]; ok {
+			values[k] = v
+		} else {
+			missing = append(missing, k)
+		}
+	}
+	if len(missing) == 0 {
+		return values, nil
+	}
+	row := map[string]any{}
+	whereSQL, args := q.pkToWhereSQL(schema, flat[q.primaryKey(schema)])
+	tableName, _ := schema["tableName"].(string)
+	err := tx.Table(tableName).Where(whereSQL, args...).Take(&row).Error
+	if err != nil {
+		return values, err
+	}
+	for _, k := range missing {
+		values[k] = row[q.mainColumn(schema, links[k])]
+	}
+	return values, nil
+}
+
+// toFlatData return the data of the schema fields with flat (dot notation) key, the array fields data is converted to []map[string]any with the array schema
+//
+// if isPartial is false, the default tag is applied to the empty fields, and the uuid primary key is generated if not setted
+func (q *DBQuery) toFlatData(schema map[string]any, data map[string]any, isPartial ...bool) map[string]any {
+	flat := map[string]any{}
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	for key, field := range fields {
+		v, ok := q.getDataValue(data, key)
+		if ok {
+			flat[key] = v
+		} else if len(isPartial) == 0 || !isPartial[0] {
+			if def, ok := field["default"].(string); ok && q.mainColumn(schema, key) != "" {
+				flat[key] = q.defaultValue(field, def)
+			}
+		}
+	}
+	if len(isPartial) == 0 || !isPartial[0] {
+		pk := q.primaryKey(schema)
+		if flat[pk] == nil && fields[pk]["type"] == "NullUUID" && q.mainColumn(schema, pk) != "" {
+			flat[pk] = uuid.NewString()
+		}
+	}
+
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	for arrayKey, arrayField := range arrayFields {
+		v, ok := q.getDataValue(data, arrayKey)
+		if !ok {
+			continue
+		}
+		arraySchema, _ := arrayField["schema"].(map[string]any)
+		arrayRows := []map[string]any{}
+		switch items := v.(type) {
+		case []map[string]any:
+			for _, item := range items {
+				arrayRows = append(arrayRows, q.toFlatData(arraySchema, item))
+			}
+		case []any:
+			for _, item := range items {
+				if mp, ok := item.(map[string]any); ok {
+					arrayRows = append(arrayRows, q.toFlatData(arraySchema, mp))
+				}
+			}
+		}
+		flat[arrayKey] = arrayRows
+	}
+	return flat
+}
+
+// getDataValue return the value of the dot notation key from nested or flat data
+func (q *DBQuery) getDataValue(data map[string]any, key string) (any, bool) {
+	if v, ok := data[key]; ok {
+		return v, true
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] == '.' {
+			if child, ok := data[key[:i]].(map[string]any); ok {
+				if v, ok := q.getDataValue(child, key[i+1:]); ok {
+					return v, true
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// defaultValue return the default tag value converted based on the field type
+func (q *DBQuery) defaultValue(field map[string]any, def string) any {
+	switch field["type"] {
+	case "NullBool", "bool":
+		if v, err := strconv.ParseBool(def); err == nil {
+			return v
+		}
+	case "NullInt64", "NullUnixTime", "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64":
+		if v, err := strconv.ParseInt(def, 10, 64); err == nil {
+			return v
+		}
+	case "NullFloat64", "float32", "float64":
+		if v, err := strconv.ParseFloat(def, 64); err == nil {
+			return v
+		}
+	}
+	return def
+}
+
+// mainColumn return the column name of the field if the field is a column of the main table, otherwise return empty string
+func (q *DBQuery) mainColumn(schema map[string]any, key string) string {
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	field, ok := fields[key]
+	if !ok {
+		return ""
+	}
+	if gormTag, _ := field["gorm"].(string); gormTag == "-" {
+		return ""
+	}
+	tableAliasName, _ := schema["tableAliasName"].(string)
+	db, _ := field["db"].(string)
+	column, isMain := strings.CutPrefix(db, tableAliasName+".")
+	if !isMain || !columnNameRegexp.MatchString(column) {
+		return ""
+	}
+	return column
+}
+
+// toColumnValues return the map of main table column to value from the flat data
+func (q *DBQuery) toColumnValues(schema map[string]any, flat map[string]any) map[string]any {
+	values := map[string]any{}
+	for key, v := range flat {
+		column := q.mainColumn(schema, key)
+		if column == "" {
+			continue
+		}
+		switch v.(type) {
+		case map[string]any, []any:
+			b, err := json.Marshal(v)
+			if err == nil {
+				v = string(b)
+			}
+		}
+		values[column] = v
+	}
+	return values
+}
+
+// pkToWhereSQL return where SQL string of the primary key column of the main table,
+// scoped by the tenant column if the table is scoped by the tenant, and the soft deleted record is excluded
+func (q *DBQuery) pkToWhereSQL(schema map[string]any, id any) (string, []any) {
+	column := q.mainColumn(schema, q.primaryKey(schema))
+	whereSQL := q.DB.Statement.Quote(column) + " = ?"
+	args := []any{id}
+	if q.isTenantSchema(schema) {
+		whereSQL += " AND " + q.DB.Statement.Quote(QueryTenantColumn) + " = ?"
+		args = append(args, q.tenant())
+	}
+	if softDeleteColumn, _ := q.softDelete(schema); softDeleteColumn != "" {
+		whereSQL += " AND " + q.DB.Statement.Quote(softDeleteColumn) + " IS NULL"
+	}
+	return whereSQL, args
+}
+
+// validateData validates the flat data based on the validate tag of the schema fields (and the array fields),
+// the error detail is keyed by the dot notation field key prefixed by prefix.
+//
+// if isPartial is true, only the setted fields are validated
+func (q *DBQuery) validateData(schema map[string]any, flat map[string]any, prefix string, isPartial bool) error {
+	v := q.validator()
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	fieldOrder, _ := schema["fieldOrder"].([]string)
+	structFields := []reflect.StructField{}
+	values := []any{}
+	for _, key := range fieldOrder {
+		tag, _ := fields[key]["validate"].(string)
+		if tag == "" {
+			continue
+		}
+		val, ok := flat[key]
+		if isPartial && !ok {
+			continue
+		}
+		structFields = append(structFields, reflect.StructField{
+			Name: "F" + strconv.Itoa(len(structFields)),
+			Type: reflect.TypeOf((*any)(nil)).Elem(),
+			Tag:  reflect.StructTag(`json:` + strconv.Quote(prefix+key) + ` validate:` + strconv.Quote(tag)),
+		})
+		values = append(values, val)
+	}
+	if len(structFields) > 0 {
+		st := reflect.New(reflect.StructOf(structFields)).Elem()
+		for i, val := range values {
+			if val != nil {
+				st.Field(i).Set(reflect.ValueOf(val))
+			}
+		}
+		err := v.ValidateStruct(st.Interface(), q.Lang)
+		if err != nil {
+			return err
+		}
+	}
+
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	arrayFieldOrder, _ := schema["arrayFieldOrder"].([]string)
+	for _, arrayKey := range arrayFieldOrder {
+		arraySchema, _ := arrayFields[arrayKey]["schema"].(map[string]any)
+		arrayRows, _ := flat[arrayKey].([]map[string]any)
+		for i, arrayRow := range arrayRows {
+			err := q.validateData(arraySchema, arrayRow, prefix+arrayKey+"."+strconv.Itoa(i)+".", false)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// validator returns the Validator of DBQuery, a new Validator (without translator) is setted if not setted
+func (q *DBQuery) validator() *Validator {
+	if q.Validator == nil {
+		q.Validator = &Validator{}
+		q.Validator.New()
+	}
+	return q.Validator
+}
+
+// transaction runs fc in a transaction
+func (q *DBQuery) transaction(fc func(tx *gorm.DB) error) error {
+	err := q.DB.Transaction(fc)
+	if err != nil {
+		return q.toError(err)
+	}
+	return nil
+}
+
+// isTransaction returns true if the DB is a transaction (of the caller or the statement timeout, see withTimeout)
+func (q *DBQuery) isTransaction() bool {
+	_, isTx := q.DB.Statement.ConnPool.(gorm.TxCommitter)
+	return isTx
+}
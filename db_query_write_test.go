@@ -0,0 +1,271 @@
+package grest
+
+import (
+	"regexp"
+	"testing"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type Order struct {
+	Model
+	ID           NullUUID    `json:"id"            db:"o.id"`
+	Number       NullString  `json:"number"        db:"o.number"     validate:"required"`
+	Status       NullString  `json:"status"        db:"o.status"     default:"draft"`
+	CustomerID   NullUUID    `json:"customer.id"   db:"o.customer_id"`
+	CustomerName NullString  `json:"customer.name" db:"c.name"`
+	Items        []OrderItem `json:"items"         db:"order.id={id}"`
+}
+
+func (Order) TableName() string {
+	return "orders"
+}
+
+func (Order) TableAliasName() string {
+	return "o"
+}
+
+func (m *Order) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+func (m *Order) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+type OrderItem struct {
+	Model
+	ID      NullUUID    `json:"id"       db:"oi.id"`
+	OrderID NullUUID    `json:"order.id" db:"oi.order_id,hide"`
+	Product NullString  `json:"product"  db:"oi.product"`
+	Qty     NullFloat64 `json:"qty"      db:"oi.qty"      validate:"min=1" default:"1"`
+}
+
+func (OrderItem) TableName() string {
+	return "order_items"
+}
+
+func (OrderItem) TableAliasName() string {
+	return "oi"
+}
+
+func (m *OrderItem) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+func (m *OrderItem) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+type Invoice struct {
+	Model
+	ID     NullInt64     `json:"id"     db:"i.id"     gorm:"primaryKey"`
+	Number NullString    `json:"number" db:"i.number"`
+	Lines  []InvoiceLine `json:"lines"  db:"invoice.id={id}"`
+}
+
+func (Invoice) TableName() string {
+	return "invoices"
+}
+
+func (Invoice) TableAliasName() string {
+	return "i"
+}
+
+func (m *Invoice) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+func (m *Invoice) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+type InvoiceLine struct {
+	Model
+	ID        NullInt64  `json:"id"         db:"il.id"         gorm:"primaryKey"`
+	InvoiceID NullInt64  `json:"invoice.id" db:"il.invoice_id"`
+	Product   NullString `json:"product"    db:"il.product"`
+}
+
+func (InvoiceLine) TableName() string {
+	return "invoice_lines"
+}
+
+func (InvoiceLine) TableAliasName() string {
+	return "il"
+}
+
+func (m *InvoiceLine) GetFields() map[string]map[string]any {
+	m.SetFields(m)
+	return m.Fields
+}
+
+func (m *InvoiceLine) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+func TestDBQueryCreate(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "orders" ("customer_id","id","number","status") VALUES ($1,$2,$3,$4)`)).
+		WithArgs("cust-1", "order-1", "SO-001", "draft").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "order_items" ("id","order_id","product","qty") VALUES ($1,$2,$3,$4)`)).
+		WithArgs("item-1", "order-1", "book", float64(1)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	id, err := Create(db, &Order{}, map[string]any{
+		"id":       "order-1",
+		"number":   "SO-001",
+		"customer": map[string]any{"id": "cust-1", "name": "John"},
+		"items":    []any{map[string]any{"id": "item-1", "product": "book"}},
+	})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if id != "order-1" {
+		t.Errorf("Expected id [order-1], got [%v]", id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations : [%v]", err)
+	}
+
+	_, err = Create(db, &Order{}, map[string]any{"items": []any{map[string]any{"qty": 0}}})
+	e, ok := err.(*Error)
+	if !ok || e.Code != 400 {
+		t.Fatalf("Expected 400 error, got [%v]", err)
+	}
+	if detail, _ := e.Detail.(map[string]any); detail["number"] == nil {
+		t.Errorf("Expected number validation error, got [%v]", e.Detail)
+	}
+
+	// the auto increment primary key is returned and linked to the has many array fields
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "invoices" ("number") VALUES ($1) RETURNING "id"`)).
+		WithArgs("INV-001").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(7)))
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO "invoice_lines" ("invoice_id","product") VALUES ($1,$2) RETURNING "id"`)).
+		WithArgs(int64(7), "book").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(1)))
+	mock.ExpectCommit()
+	id, err = Create(db, &Invoice{}, map[string]any{"number": "INV-001", "lines": []any{map[string]any{"product": "book"}}})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	if id != int64(7) {
+		t.Errorf("Expected id [7], got [%v]", id)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations : [%v]", err)
+	}
+}
+
+func TestDBQueryUpdate(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "status"=$1 WHERE "id" = $2`)).
+		WithArgs("paid", "order-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "order_items" WHERE "order_id" = $1`)).
+		WithArgs("order-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`INSERT INTO "order_items"`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err = Patch(db, &Order{}, "order-1", map[string]any{
+		"status": "paid",
+		"items":  []any{map[string]any{"product": "pen", "qty": 2}},
+	})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	err = Update(db, &Order{}, "order-1", map[string]any{"status": "paid"})
+	if e, ok := err.(*Error); !ok || e.Code != 400 {
+		t.Errorf("Expected 400 error, got [%v]", err)
+	}
+
+	// the unchanged values (0 affected rows on mysql) is not treated as not found
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "number"=$1 WHERE "id" = $2`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "orders" WHERE "id" = $1`)).
+		WithArgs("order-1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+	err = Update(db, &Order{}, "order-1", map[string]any{"number": "SO-001"})
+	if err != nil {
+		t.Errorf("Expected no error for unchanged values, got [%v]", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "orders" SET "number"=$1 WHERE "id" = $2`)).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*) FROM "orders" WHERE "id" = $1`)).
+		WithArgs("order-2").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+	err = Update(db, &Order{}, "order-2", map[string]any{"number": "SO-002"})
+	if e, ok := err.(*Error); !ok || e.Code != 404 {
+		t.Errorf("Expected 404 error, got [%v]", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations : [%v]", err)
+	}
+}
+
+func TestDBQueryDelete(t *testing.T) {
+	db, mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "order_items" WHERE "order_id" = $1`)).
+		WithArgs("order-1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM "orders" WHERE "id" = $1`)).
+		WithArgs("order-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = Delete(db, &Order{}, "order-1")
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// the model with deleted_at is soft deleted
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "deleted_at"=$1 WHERE "id" = $2 AND "deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), "c1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	err = Delete(db, &Category{}, "c1")
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// the soft deleted record is not found
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "deleted_at"=$1 WHERE "id" = $2 AND "deleted_at" IS NULL`)).
+		WithArgs(sqlmock.AnyArg(), "c1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	err = Delete(db, &Category{}, "c1")
+	if e, ok := err.(*Error); !ok || e.Code != 404 {
+		t.Errorf("Expected 404 error, got [%v]", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("Unfulfilled expectations : [%v]", err)
+	}
+}
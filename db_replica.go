@@ -0,0 +1,212 @@
+package grest
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// read replica routing setting, see Replicas
+var (
+	DBReplicaRoundRobin   = "round_robin"   // pick the healthy replicas in turn
+	DBReplicaLeastLatency = "least_latency" // pick the healthy replica with the lowest ping latency of the last Replicas.Check
+	DBReplicaPingTimeout  = 2 * time.Second // the replica is ejected if the ping is not responded within the timeout
+)
+
+// Replicas is the read replicas of a registered connection (the primary), see DB.RegisterReplica
+//
+// DBQuery with Replicas runs Find, First, Count and FindEach on a healthy replica, while the writes are executed on DBQuery.DB (the primary).
+// the replica is ejected when its ping is failed and rejoined when its ping is succeeded, so Check must be called periodically :
+//
+//	go func() {
+//		for range time.Tick(10 * time.Second) {
+//			db.CheckReplicas()
+//		}
+//	}()
+type Replicas struct {
+	Policy string // DBReplicaRoundRobin (default) or DBReplicaLeastLatency
+	conns  []*replica
+	next   atomic.Uint64
+	mu     sync.RWMutex
+}
+
+// replica is the read replica connection with its health state
+type replica struct {
+	conn      *gorm.DB
+	latency   atomic.Int64
+	isEjected atomic.Bool
+}
+
+// Add adds a read replica connection
+func (r *Replicas) Add(conn *gorm.DB) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.conns = append(r.conns, &replica{conn: conn})
+}
+
+// Conn returns a healthy replica connection based on the Policy, nil if there is no healthy replica
+func (r *Replicas) Conn() *gorm.DB {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	healthy := make([]*replica, 0, len(r.conns))
+	for _, rep := range r.conns {
+		if !rep.isEjected.Load() {
+			healthy = append(healthy, rep)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if r.Policy == DBReplicaLeastLatency {
+		fastest := healthy[0]
+		for _, rep := range healthy[1:] {
+			if rep.latency.Load() < fastest.latency.Load() {
+				fastest = rep
+			}
+		}
+		return fastest.conn
+	}
+	return healthy[(r.next.Add(1)-1)%uint64(len(healthy))].conn
+}
+
+// Check pings all replicas to measure their latency, the replica which is failed to respond within DBReplicaPingTimeout is ejected
+func (r *Replicas) Check() {
+	r.mu.RLock()
+	conns := append([]*replica{}, r.conns...)
+	r.mu.RUnlock()
+	for _, rep := range conns {
+		start := time.Now()
+		err := rep.ping()
+		rep.latency.Store(int64(time.Since(start)))
+		rep.isEjected.Store(err != nil)
+	}
+}
+
+// close closes all replica connections
+func (r *Replicas) close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rep := range r.conns {
+		dbSQL, err := rep.conn.DB()
+		if err == nil {
+			dbSQL.Close()
+		}
+	}
+	r.conns = nil
+}
+
+// ping pings the replica connection within DBReplicaPingTimeout
+func (rep *replica) ping() error {
+	dbSQL, err := rep.conn.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DBReplicaPingTimeout)
+	defer cancel()
+	return dbSQL.PingContext(ctx)
+}
+
+// RegisterReplica registers a read replica of the registered connection (the primary),
+// the migrations and the seeders are always run on the primary
+func (db *DB) RegisterReplica(connName string, conn *gorm.DB) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.Replicas == nil {
+		db.Replicas = map[string]*Replicas{}
+	}
+	if db.Replicas[connName] == nil {
+		db.Replicas[connName] = &Replicas{}
+	}
+	db.Replicas[connName].Add(conn)
+}
+
+// ConnReplicas retrieves the read replicas of a registered connection, nil if there is no registered replica
+//
+//	q := &grest.DBQuery{DB: primary, Replicas: db.ConnReplicas("main")}
+func (db *DB) ConnReplicas(connName string) *Replicas {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+	return db.Replicas[connName]
+}
+
+// CheckReplicas checks the health of all registered replicas, see Replicas.Check
+func (db *DB) CheckReplicas() {
+	db.mu.RLock()
+	replicas := make([]*Replicas, 0, len(db.Replicas))
+	for _, r := range db.Replicas {
+		replicas = append(replicas, r)
+	}
+	db.mu.RUnlock()
+	for _, r := range replicas {
+		r.Check()
+	}
+}
+
+// readYourWritesContextKey is the context key of the write marker, see ContextWithReadYourWrites
+type readYourWritesContextKey struct{}
+
+// ContextWithReadYourWrites returns a copy of ctx which routes the reads of DBQuery to the primary after a write of DBQuery using the ctx,
+// used to keep the reads after the writes of a request consistent without waiting the replication lag
+//
+//	db = db.WithContext(grest.ContextWithReadYourWrites(c.Context()))
+func ContextWithReadYourWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readYourWritesContextKey{}, &atomic.Bool{})
+}
+
+// markWritten marks the DBQuery and its context as written, so the next reads are routed to the primary
+func (q *DBQuery) markWritten() {
+	q.isWritten = true
+	if q.DB == nil || q.DB.Statement == nil || q.DB.Statement.Context == nil {
+		return
+	}
+	if isWritten, _ := q.DB.Statement.Context.Value(readYourWritesContextKey{}).(*atomic.Bool); isWritten != nil {
+		isWritten.Store(true)
+	}
+}
+
+// isReadFromPrimary returns true if the reads must be routed to the primary : after a write or inside a transaction
+func (q *DBQuery) isReadFromPrimary() bool {
+	if q.isWritten {
+		return true
+	}
+	if q.isTransaction() {
+		return true
+	}
+	if q.DB.Statement.Context == nil {
+		return false
+	}
+	isWritten, _ := q.DB.Statement.Context.Value(readYourWritesContextKey{}).(*atomic.Bool)
+	return isWritten != nil && isWritten.Load()
+}
+
+// withReplica runs fn with a healthy replica of DBQuery.Replicas as the DB (using the context of the primary),
+// fn is run on the primary if there is no healthy replica or the reads must be routed to the primary, see isReadFromPrimary
+func (q *DBQuery) withReplica(fn func() error) error {
+	if q.isInternal || q.Replicas == nil || q.isReadFromPrimary() {
+		return fn()
+	}
+	conn := q.Replicas.Conn()
+	if conn == nil {
+		return fn()
+	}
+	db := q.DB
+	defer func() { q.DB = db }()
+	if db.Statement.Context != nil {
+		conn = conn.WithContext(db.Statement.Context)
+	}
+	q.DB = conn
+	return fn()
+}
+
+// read runs the read query fn on a replica with the query timeout, see withReplica and withTimeout
+func (q *DBQuery) read(fn func() error) error {
+	return q.withReplica(func() error {
+		return q.withTimeout(fn)
+	})
+}
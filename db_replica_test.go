@@ -0,0 +1,106 @@
+package grest
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+	"gorm.io/gorm"
+)
+
+// closeMockDB closes the mock db, so its ping is failed
+func closeMockDB(db *gorm.DB) {
+	dbSQL, _ := db.DB()
+	dbSQL.Close()
+}
+
+func TestDBQueryReplicas(t *testing.T) {
+	primary, primaryMock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	replica1, replica1Mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	replica2, replica2Mock, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	db := &DB{}
+	db.RegisterConn("main", primary)
+	db.RegisterReplica("main", replica1)
+	db.RegisterReplica("main", replica2)
+	replicas := db.ConnReplicas("main")
+	schema := (&Category{}).GetSchema()
+	categories := func() *sqlmock.Rows { return sqlmock.NewRows([]string{"id"}).AddRow("c1") }
+
+	// round robin
+	replica1Mock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	replica2Mock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	replica1Mock.ExpectQuery(regexp.QuoteMeta(`SELECT count(*)`)).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	q := &DBQuery{DB: primary, Replicas: replicas}
+	for i := 0; i < 2; i++ {
+		if _, err := q.Find(schema, url.Values{}); err != nil {
+			t.Fatalf("Error occured : [%v]", err.Error())
+		}
+	}
+	if _, err := q.Count(schema, url.Values{}); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// least latency and ejection of the unhealthy replica
+	replicas.Policy = DBReplicaLeastLatency
+	replicas.conns[0].latency.Store(int64(10 * time.Millisecond))
+	replicas.conns[1].latency.Store(int64(time.Millisecond))
+	replica2Mock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	if _, err := q.Find(schema, url.Values{}); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	closeMockDB(replica2)
+	db.CheckReplicas()
+	replica1Mock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	if _, err := q.Find(schema, url.Values{}); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// read your writes of the request context, and the reads inside a transaction
+	ctx := ContextWithReadYourWrites(context.Background())
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectExec(regexp.QuoteMeta(`UPDATE "categories" SET "deleted_at"=`)).WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectCommit()
+	if err := (&DBQuery{DB: primary.WithContext(ctx), Replicas: replicas}).Delete(schema, "c1"); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	primaryMock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	if _, err := (&DBQuery{DB: primary.WithContext(ctx), Replicas: replicas}).Find(schema, url.Values{}); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	primaryMock.ExpectCommit()
+	err = primary.Transaction(func(tx *gorm.DB) error {
+		_, err := (&DBQuery{DB: tx, Replicas: replicas}).Find(schema, url.Values{})
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	// the primary is used if all replicas are ejected
+	closeMockDB(replica1)
+	db.CheckReplicas()
+	primaryMock.ExpectQuery(regexp.QuoteMeta(`FROM "categories"`)).WillReturnRows(categories())
+	if _, err := q.Find(schema, url.Values{}); err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+
+	for name, mock := range map[string]sqlmock.Sqlmock{"primary": primaryMock, "replica1": replica1Mock, "replica2": replica2Mock} {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("Expectations of %v were not met : [%v]", name, err)
+		}
+	}
+}
@@ -0,0 +1,551 @@
+package grest
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect defines the database specific SQL used by DBQuery and the column types of the grest data types.
+//
+// the dialect is selected by gorm Dialector.Name(), the built in dialects are postgres, mysql, sqlite, sqlserver, firebird and clickhouse,
+// use RegisterDialect to register your own dialect (or override the built in one), embed ANSIDialect to only override the different part.
+//
+// example :
+//
+//	type OracleDialect struct {
+//		grest.ANSIDialect
+//	}
+//
+//	func (OracleDialect) Name() string {
+//		return "oracle"
+//	}
+//
+//	func (OracleDialect) NewUUIDSQL() string {
+//		return "LOWER(REGEXP_REPLACE(RAWTOHEX(SYS_GUID()), '(.{8})(.{4})(.{4})(.{4})(.{12})', '\1-\2-\3-\4-\5'))"
+//	}
+//
+//	func init() {
+//		grest.RegisterDialect(OracleDialect{})
+//	}
+type Dialect interface {
+	// Name returns the dialect name, same as gorm Dialector.Name()
+	Name() string
+
+	// Quote returns quoted identifier (table name, column name or alias)
+	Quote(identifier string) string
+
+	// QuoteJSON returns json extract SQL string of the quoted column with dot notation json key
+	QuoteJSON(column, jsonKey string) string
+
+	// Cast returns SQL string of expr casted to the data type returned by DataType
+	Cast(expr, dataType string) string
+
+	// DataType returns the data type of the standard data type (the key of StandardDataTypes), empty string if not supported
+	DataType(standardDataType string) string
+
+	// NewUUIDSQL returns SQL string to generate new uuid, empty string if not supported
+	NewUUIDSQL() string
+
+	// LikeColumn returns SQL string of the non string column (uuid, number, date, etc) to be compared using LIKE operator
+	LikeColumn(column string) string
+
+	// ILike returns case insensitive operator of the LIKE or NOT LIKE operator, empty string if not supported (LOWER is used instead)
+	ILike(operator string) string
+
+	// ColumnType returns the column type of the grest data type name (ex: NullDate, NullJSON), empty string if not supported
+	ColumnType(typeName string) string
+
+	// StatementTimeout returns SQL string to set the statement timeout (in milliseconds) inside a transaction,
+	// and SQL string to reset it if the setting outlives the transaction, empty string if not supported
+	StatementTimeout(ms int64) (string, string)
+
+	// Explain returns SQL string to explain the query plan of the SQL string, or to execute and explain it if isAnalyze is true,
+	// empty string if not supported
+	Explain(sql string, isAnalyze bool) string
+}
+
+// StandardDataTypes is the standard data types used by QueryCast, the Alternatives is keyed by dialect name
+var StandardDataTypes = map[string]StandardDataType{
+	"int": {Default: "INT", Alternatives: map[string]string{
+		"mysql": "SIGNED", "sqlite": "INTEGER", "firebird": "INTEGER", "clickhouse": "Int32",
+	}},
+	"bigint": {Default: "BIGINT", Alternatives: map[string]string{
+		"mysql": "SIGNED", "sqlite": "INTEGER", "clickhouse": "Int64",
+	}},
+	"float": {Default: "FLOAT", Alternatives: map[string]string{
+		"postgres": "REAL", "sqlite": "REAL", "clickhouse": "Float32",
+	}},
+	"double": {Default: "DOUBLE", Alternatives: map[string]string{
+		"postgres": "DOUBLE PRECISION", "sqlite": "REAL", "sqlserver": "FLOAT", "firebird": "DOUBLE PRECISION", "clickhouse": "Float64",
+	}},
+	"decimal": {Default: "DECIMAL", Alternatives: map[string]string{
+		"sqlite": "NUMERIC", "clickhouse": "Decimal(38, 10)",
+	}},
+	"char": {Default: "CHAR", Alternatives: map[string]string{
+		"sqlite": "TEXT", "sqlserver": "NCHAR", "firebird": "VARCHAR(8191)", "clickhouse": "String",
+	}},
+	"varchar": {Default: "VARCHAR", Alternatives: map[string]string{
+		"mysql": "CHAR", "sqlite": "TEXT", "sqlserver": "NVARCHAR(MAX)", "firebird": "VARCHAR(8191)", "clickhouse": "String",
+	}},
+	"text": {Default: "TEXT", Alternatives: map[string]string{
+		"mysql": "CHAR", "sqlserver": "NVARCHAR(MAX)", "firebird": "VARCHAR(8191)", "clickhouse": "String",
+	}},
+	"date": {Default: "DATE", Alternatives: map[string]string{
+		"clickhouse": "Date",
+	}},
+	"time": {Default: "TIME", Alternatives: map[string]string{
+		"clickhouse": "String",
+	}},
+	"timestamp": {Default: "TIMESTAMP", Alternatives: map[string]string{
+		"mysql": "DATETIME", "sqlite": "DATETIME", "sqlserver": "DATETIME2", "clickhouse": "DateTime",
+	}},
+	"boolean": {Default: "BOOLEAN", Alternatives: map[string]string{
+		"mysql": "SIGNED", "sqlite": "INTEGER", "sqlserver": "BIT", "clickhouse": "Bool",
+	}},
+	"binary": {Default: "BINARY", Alternatives: map[string]string{
+		"postgres": "BYTEA", "sqlite": "BLOB", "sqlserver": "VARBINARY(MAX)", "firebird": "BLOB", "clickhouse": "String",
+	}},
+	"blob": {Default: "BLOB", Alternatives: map[string]string{
+		"postgres": "BYTEA", "mysql": "BINARY", "sqlserver": "VARBINARY(MAX)", "clickhouse": "String",
+	}},
+	"json": {Default: "JSON", Alternatives: map[string]string{
+		"sqlite": "TEXT", "sqlserver": "NVARCHAR(MAX)", "firebird": "VARCHAR(8191)", "clickhouse": "String",
+	}},
+}
+
+var (
+	dialects   = map[string]Dialect{}
+	dialectsMu sync.RWMutex
+)
+
+func init() {
+	RegisterDialect(PostgresDialect{})
+	RegisterDialect(MySQLDialect{})
+	RegisterDialect(SQLiteDialect{})
+	RegisterDialect(SQLServerDialect{})
+	RegisterDialect(FirebirdDialect{})
+	RegisterDialect(ClickHouseDialect{})
+}
+
+// RegisterDialect registers the dialect keyed by its name, the registered dialect with the same name is replaced
+func RegisterDialect(d Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[d.Name()] = d
+}
+
+// GetDialect returns the registered dialect by name, ANSIDialect is returned if not registered
+func GetDialect(name string) Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	if d, ok := dialects[name]; ok {
+		return d
+	}
+	return ANSIDialect{}
+}
+
+// dialectDataType returns the data type of the standard data type for the dialect name
+func dialectDataType(standardDataType, name string) string {
+	dataType, ok := StandardDataTypes[standardDataType]
+	if !ok {
+		return ""
+	}
+	if res := dataType.Alternatives[name]; res != "" {
+		return res
+	}
+	return dataType.Default
+}
+
+// ANSIDialect is the default dialect for unregistered database, also used as base of the other dialects
+type ANSIDialect struct{}
+
+// Name returns the dialect name
+func (ANSIDialect) Name() string {
+	return "ansi"
+}
+
+// Quote returns quoted identifier
+func (ANSIDialect) Quote(identifier string) string {
+	return `"` + identifier + `"`
+}
+
+// QuoteJSON returns the column as is because json is not supported
+func (ANSIDialect) QuoteJSON(column, jsonKey string) string {
+	return column
+}
+
+// Cast returns CAST SQL string
+func (ANSIDialect) Cast(expr, dataType string) string {
+	return "CAST(" + expr + " AS " + dataType + ")"
+}
+
+// DataType returns the default of the standard data type
+func (ANSIDialect) DataType(standardDataType string) string {
+	dataType, _ := StandardDataTypes[standardDataType]
+	return dataType.Default
+}
+
+// NewUUIDSQL returns empty string because uuid is not supported
+func (ANSIDialect) NewUUIDSQL() string {
+	return ""
+}
+
+// LikeColumn returns the column as is
+func (ANSIDialect) LikeColumn(column string) string {
+	return column
+}
+
+// ILike returns empty string because ILIKE is not supported
+func (ANSIDialect) ILike(operator string) string {
+	return ""
+}
+
+// ColumnType returns the common column type of the grest data type
+func (ANSIDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullUnixTime":
+		return "INTEGER"
+	case "NullDate":
+		return "DATE"
+	case "NullTime":
+		return "TIME"
+	case "NullText", "NullJSON":
+		return "TEXT"
+	case "NullUUID":
+		return "char(36)"
+	}
+	return ""
+}
+
+// StatementTimeout returns empty string because statement timeout is not supported
+func (ANSIDialect) StatementTimeout(ms int64) (string, string) {
+	return "", ""
+}
+
+// Explain returns EXPLAIN SQL string, empty string if isAnalyze is true because EXPLAIN ANALYZE is not standard
+func (ANSIDialect) Explain(sql string, isAnalyze bool) string {
+	if isAnalyze {
+		return ""
+	}
+	return "EXPLAIN " + sql
+}
+
+// PostgresDialect is the dialect of PostgreSQL
+type PostgresDialect struct {
+	ANSIDialect
+}
+
+// Name returns the dialect name
+func (PostgresDialect) Name() string {
+	return "postgres"
+}
+
+// QuoteJSON returns json_extract_path_text SQL string
+func (PostgresDialect) QuoteJSON(column, jsonKey string) string {
+	return "json_extract_path_text(" + column + "::json," + jsonPathArgs(jsonKey) + ")"
+}
+
+// DataType returns the postgres data type of the standard data type
+func (d PostgresDialect) DataType(standardDataType string) string {
+	return dialectDataType(standardDataType, d.Name())
+}
+
+// NewUUIDSQL returns SQL string to generate new uuid
+func (PostgresDialect) NewUUIDSQL() string {
+	return "md5(random()::text || clock_timestamp()::text)::uuid"
+}
+
+// LikeColumn returns the column casted to text because LIKE is only for string
+func (PostgresDialect) LikeColumn(column string) string {
+	return "CAST(" + column + " AS text)"
+}
+
+// ILike returns ILIKE or NOT ILIKE
+func (PostgresDialect) ILike(operator string) string {
+	return strings.Replace(strings.ToUpper(operator), "LIKE", "ILIKE", 1)
+}
+
+// ColumnType returns the postgres column type of the grest data type
+func (d PostgresDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullUnixTime":
+		return "bigint"
+	case "NullJSON":
+		return "JSONB"
+	case "NullUUID":
+		return "uuid"
+	}
+	return d.ANSIDialect.ColumnType(typeName)
+}
+
+// StatementTimeout returns SET LOCAL statement_timeout which is reset at the end of the transaction
+func (PostgresDialect) StatementTimeout(ms int64) (string, string) {
+	return "SET LOCAL statement_timeout = " + strconv.FormatInt(ms, 10), ""
+}
+
+// Explain returns EXPLAIN or EXPLAIN ANALYZE SQL string
+func (PostgresDialect) Explain(sql string, isAnalyze bool) string {
+	if isAnalyze {
+		return "EXPLAIN ANALYZE " + sql
+	}
+	return "EXPLAIN " + sql
+}
+
+// MySQLDialect is the dialect of MySQL and MariaDB
+type MySQLDialect struct {
+	ANSIDialect
+}
+
+// Name returns the dialect name
+func (MySQLDialect) Name() string {
+	return "mysql"
+}
+
+// Quote returns quoted identifier using backtick
+func (MySQLDialect) Quote(identifier string) string {
+	return "`" + identifier + "`"
+}
+
+// QuoteJSON returns JSON_EXTRACT SQL string
+func (MySQLDialect) QuoteJSON(column, jsonKey string) string {
+	return "JSON_EXTRACT(" + column + ",'$." + jsonKey + "')"
+}
+
+// DataType returns the mysql data type of the standard data type
+func (d MySQLDialect) DataType(standardDataType string) string {
+	return dialectDataType(standardDataType, d.Name())
+}
+
+// NewUUIDSQL returns SQL string to generate new uuid
+func (MySQLDialect) NewUUIDSQL() string {
+	return "UUID()"
+}
+
+// StatementTimeout returns SET SESSION max_execution_time, reset to the global value after the query
+//
+// MariaDB uses max_statement_time (in seconds) instead, register your own dialect to override it
+func (MySQLDialect) StatementTimeout(ms int64) (string, string) {
+	return "SET SESSION max_execution_time = " + strconv.FormatInt(ms, 10), "SET SESSION max_execution_time = DEFAULT"
+}
+
+// ColumnType returns the mysql column type of the grest data type
+func (d MySQLDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullUnixTime":
+		return "BIGINT"
+	case "NullJSON":
+		return "JSON"
+	}
+	return d.ANSIDialect.ColumnType(typeName)
+}
+
+// Explain returns EXPLAIN or EXPLAIN ANALYZE (MySQL 8.0.18+) SQL string
+func (MySQLDialect) Explain(sql string, isAnalyze bool) string {
+	if isAnalyze {
+		return "EXPLAIN ANALYZE " + sql
+	}
+	return "EXPLAIN " + sql
+}
+
+// SQLiteDialect is the dialect of SQLite
+type SQLiteDialect struct {
+	ANSIDialect
+}
+
+// Name returns the dialect name
+func (SQLiteDialect) Name() string {
+	return "sqlite"
+}
+
+// Quote returns quoted identifier using backtick
+func (SQLiteDialect) Quote(identifier string) string {
+	return "`" + identifier + "`"
+}
+
+// QuoteJSON returns JSON_EXTRACT SQL string
+func (SQLiteDialect) QuoteJSON(column, jsonKey string) string {
+	return "JSON_EXTRACT(" + column + ",'$." + jsonKey + "')"
+}
+
+// Cast returns CAST SQL string, date and time are converted using date and time functions because sqlite has no date and time type
+func (d SQLiteDialect) Cast(expr, dataType string) string {
+	switch dataType {
+	case "DATE":
+		return "DATE(" + expr + ")"
+	case "TIME":
+		return "TIME(" + expr + ")"
+	case "DATETIME":
+		return "DATETIME(" + expr + ")"
+	}
+	return d.ANSIDialect.Cast(expr, dataType)
+}
+
+// DataType returns the sqlite data type of the standard data type
+func (d SQLiteDialect) DataType(standardDataType string) string {
+	return dialectDataType(standardDataType, d.Name())
+}
+
+// NewUUIDSQL returns SQL string to generate new uuid
+func (SQLiteDialect) NewUUIDSQL() string {
+	return "lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || substr(lower(hex(randomblob(2))),2) || '-' || substr('89ab',abs(random()) % 4 + 1, 1) || substr(lower(hex(randomblob(2))),2) || '-' || lower(hex(randomblob(6)))"
+}
+
+// ColumnType returns the sqlite column type of the grest data type
+func (d SQLiteDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullDate", "NullTime":
+		return "TEXT"
+	case "NullJSON":
+		return "JSON"
+	}
+	return d.ANSIDialect.ColumnType(typeName)
+}
+
+// Explain returns EXPLAIN QUERY PLAN SQL string, empty string if isAnalyze is true because it is not supported
+func (SQLiteDialect) Explain(sql string, isAnalyze bool) string {
+	if isAnalyze {
+		return ""
+	}
+	return "EXPLAIN QUERY PLAN " + sql
+}
+
+// SQLServerDialect is the dialect of Microsoft SQL Server
+type SQLServerDialect struct {
+	ANSIDialect
+}
+
+// Name returns the dialect name
+func (SQLServerDialect) Name() string {
+	return "sqlserver"
+}
+
+// QuoteJSON returns JSON_VALUE SQL string
+func (SQLServerDialect) QuoteJSON(column, jsonKey string) string {
+	return "JSON_VALUE(" + column + ",'$." + jsonKey + "')"
+}
+
+// DataType returns the sqlserver data type of the standard data type
+func (d SQLServerDialect) DataType(standardDataType string) string {
+	return dialectDataType(standardDataType, d.Name())
+}
+
+// NewUUIDSQL returns SQL string to generate new uuid
+func (SQLServerDialect) NewUUIDSQL() string {
+	return "LOWER(CAST(NEWID() AS CHAR(36)))"
+}
+
+// ColumnType returns the sqlserver column type of the grest data type
+func (d SQLServerDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullText", "NullJSON":
+		return "NVARCHAR(MAX)"
+	}
+	return d.ANSIDialect.ColumnType(typeName)
+}
+
+// Explain returns empty string because the query plan is only available using SET SHOWPLAN in a separate batch
+func (SQLServerDialect) Explain(sql string, isAnalyze bool) string {
+	return ""
+}
+
+// FirebirdDialect is the dialect of Firebird
+type FirebirdDialect struct {
+	ANSIDialect
+}
+
+// Name returns the dialect name
+func (FirebirdDialect) Name() string {
+	return "firebird"
+}
+
+// DataType returns the firebird data type of the standard data type
+func (d FirebirdDialect) DataType(standardDataType string) string {
+	return dialectDataType(standardDataType, d.Name())
+}
+
+// NewUUIDSQL returns SQL string to generate new uuid
+func (FirebirdDialect) NewUUIDSQL() string {
+	return "LOWER(UUID_TO_CHAR(GEN_UUID()))"
+}
+
+// ColumnType returns the firebird column type of the grest data type
+func (d FirebirdDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullText", "NullJSON":
+		return "BLOB SUB_TYPE TEXT"
+	}
+	return d.ANSIDialect.ColumnType(typeName)
+}
+
+// Explain returns empty string because the query plan is not available using SQL
+func (FirebirdDialect) Explain(sql string, isAnalyze bool) string {
+	return ""
+}
+
+// ClickHouseDialect is the dialect of ClickHouse
+type ClickHouseDialect struct {
+	ANSIDialect
+}
+
+// Name returns the dialect name
+func (ClickHouseDialect) Name() string {
+	return "clickhouse"
+}
+
+// Quote returns quoted identifier using backtick
+func (ClickHouseDialect) Quote(identifier string) string {
+	return "`" + identifier + "`"
+}
+
+// QuoteJSON returns JSONExtractString SQL string
+func (ClickHouseDialect) QuoteJSON(column, jsonKey string) string {
+	return "JSONExtractString(" + column + "," + jsonPathArgs(jsonKey) + ")"
+}
+
+// DataType returns the clickhouse data type of the standard data type
+func (d ClickHouseDialect) DataType(standardDataType string) string {
+	return dialectDataType(standardDataType, d.Name())
+}
+
+// NewUUIDSQL returns SQL string to generate new uuid
+func (ClickHouseDialect) NewUUIDSQL() string {
+	return "toString(generateUUIDv4())"
+}
+
+// LikeColumn returns the column converted to string because LIKE is only for string
+func (ClickHouseDialect) LikeColumn(column string) string {
+	return "toString(" + column + ")"
+}
+
+// ILike returns ILIKE or NOT ILIKE
+func (ClickHouseDialect) ILike(operator string) string {
+	return strings.Replace(strings.ToUpper(operator), "LIKE", "ILIKE", 1)
+}
+
+// ColumnType returns the clickhouse column type of the grest data type
+func (ClickHouseDialect) ColumnType(typeName string) string {
+	switch typeName {
+	case "NullUnixTime":
+		return "Nullable(Int64)"
+	case "NullDate":
+		return "Nullable(Date32)"
+	case "NullTime", "NullText", "NullJSON":
+		return "Nullable(String)"
+	case "NullUUID":
+		return "Nullable(UUID)"
+	}
+	return ""
+}
+
+// jsonPathArgs returns comma separated quoted keys of the dot notation json key, ex: 'a','b'
+func jsonPathArgs(jsonKey string) string {
+	jsonPath := strings.Builder{}
+	for idx, key := range strings.Split(jsonKey, ".") {
+		if idx > 0 {
+			jsonPath.WriteString(",")
+		}
+		jsonPath.WriteString("'" + key + "'")
+	}
+	return jsonPath.String()
+}
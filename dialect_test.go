@@ -0,0 +1,90 @@
+package grest
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testDialect struct {
+	ANSIDialect
+}
+
+func (testDialect) Name() string {
+	return "test"
+}
+
+func (testDialect) NewUUIDSQL() string {
+	return "NEW_UUID()"
+}
+
+func TestDialect(t *testing.T) {
+	if d := GetDialect("test"); d.Name() != "ansi" {
+		t.Errorf("Expected ansi dialect for unregistered name, got [%v]", d.Name())
+	}
+	RegisterDialect(testDialect{})
+	defer func() {
+		dialectsMu.Lock()
+		delete(dialects, "test")
+		dialectsMu.Unlock()
+	}()
+	d := GetDialect("test")
+	if d.NewUUIDSQL() != "NEW_UUID()" || d.Quote("a") != `"a"` {
+		t.Errorf("Expected registered test dialect, got [%v]", d.Name())
+	}
+
+	mysqlTimeout, mysqlResetTimeout := GetDialect("mysql").StatementTimeout(500)
+	sqliteTimeout, _ := GetDialect("sqlite").StatementTimeout(500)
+	tests := []struct {
+		name     string
+		got      string
+		expected string
+	}{
+		{"postgres json", GetDialect("postgres").QuoteJSON(`"a"."detail"`, "b.c"), `json_extract_path_text("a"."detail"::json,'b','c')`},
+		{"mysql json", GetDialect("mysql").QuoteJSON("`a`.`detail`", "b.c"), "JSON_EXTRACT(`a`.`detail`,'$.b.c')"},
+		{"clickhouse json", GetDialect("clickhouse").QuoteJSON("`detail`", "b"), "JSONExtractString(`detail`,'b')"},
+		{"mysql cast", GetDialect("mysql").Cast("x", GetDialect("mysql").DataType("int")), "CAST(x AS SIGNED)"},
+		{"sqlite cast", GetDialect("sqlite").Cast("x", GetDialect("sqlite").DataType("date")), "DATE(x)"},
+		{"sqlserver cast", GetDialect("sqlserver").Cast("x", GetDialect("sqlserver").DataType("boolean")), "CAST(x AS BIT)"},
+		{"unknown data type", GetDialect("postgres").DataType("unknown"), ""},
+		{"postgres ilike", GetDialect("postgres").ILike("NOT LIKE"), "NOT ILIKE"},
+		{"mysql ilike", GetDialect("mysql").ILike("LIKE"), ""},
+		{"postgres column type", GetDialect("postgres").ColumnType("NullJSON"), "JSONB"},
+		{"sqlserver column type", GetDialect("sqlserver").ColumnType("NullText"), "NVARCHAR(MAX)"},
+		{"firebird column type", GetDialect("firebird").ColumnType("NullUUID"), "char(36)"},
+		{"clickhouse column type", GetDialect("clickhouse").ColumnType("NullDate"), "Nullable(Date32)"},
+		{"mysql statement timeout", mysqlTimeout + ";" + mysqlResetTimeout, "SET SESSION max_execution_time = 500;SET SESSION max_execution_time = DEFAULT"},
+		{"sqlite statement timeout", sqliteTimeout, ""},
+		{"postgres explain analyze", GetDialect("postgres").Explain("SELECT 1", true), "EXPLAIN ANALYZE SELECT 1"},
+		{"sqlite explain", GetDialect("sqlite").Explain("SELECT 1", false), "EXPLAIN QUERY PLAN SELECT 1"},
+		{"sqlite explain analyze", GetDialect("sqlite").Explain("SELECT 1", true), ""},
+		{"sqlserver explain", GetDialect("sqlserver").Explain("SELECT 1", false), ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Errorf("%v: expected [%v], got [%v]", tt.name, tt.expected, tt.got)
+		}
+	}
+}
+
+func TestDBQueryDialect(t *testing.T) {
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	dq := &DBQuery{DB: db}
+	q := url.Values{}
+	q.Add("title."+QueryOptInsensitiveLike, "Foo")
+	q.Add("id."+QueryOptNotLike, "abc")
+	q.Add("created_at:timestamp."+QueryOptGreaterThan, "2024-01-01")
+	sql := dq.ToSQL((&Article{}).GetSchema(), q)
+	for _, expected := range []string{
+		`"a"."title" ILIKE '%Foo%'`,
+		`CAST("a"."id" AS text) NOT LIKE '%abc%'`,
+		`CAST("a"."created_at" AS TIMESTAMP)>'2024-01-01'`,
+	} {
+		if !strings.Contains(sql, expected) {
+			t.Errorf("Expected [%v] in:\n%v", expected, sql)
+		}
+	}
+}
@@ -74,6 +74,16 @@ type ModelInterface interface {
 //
 // example :
 //   - used as "example" on OpenAPI Specification
+//
+// filter :
+//   - "-" to disallow the field to be filtered by client's query params
+//   - or comma separated allowed operators, ex: filter:"$eq,$in"
+//
+// sort :
+//   - "-" to disallow the field to be sorted by client's query params
+//
+// group :
+//   - "-" to disallow the field to be grouped by client's query params
 type Model struct {
 	// described in the following pattern : map[fieldKey]map[optKey]optValue
 	// fieldKey: the field shown on json
@@ -100,6 +110,7 @@ type Model struct {
 	// - tableSchema : schema for dynamic "join sub query" based on client's query params
 	// - tableAliasName : table alias name
 	// - conditions : []map[string]any same as "Filters"
+	// - isRequired : if true, the left join is always joined, even if it is not referenced by the query
 	Relations     map[string]map[string]any `json:"-" gorm:"-"`
 	RelationOrder []string                  `json:"-" gorm:"-"`
 
@@ -157,8 +168,7 @@ func (m *Model) TableName() string {
 // for example :
 //
 //	func (m *Model) TableSchema() map[string]any {
-//		ur := &UserReviewTotal{}
-//		return ur.GetSchema()
+//		return grest.GetSchema(&UserReviewTotal{})
 //	}
 func (m *Model) TableSchema() map[string]any {
 	return nil
@@ -189,8 +199,9 @@ func (m *Model) SetFields(p any) {
 			}
 			isArray := field.Type.Kind() == reflect.Slice
 			if isArray {
-				gqs := m.callMethod(reflect.New(field.Type.Elem()), "GetSchema", []reflect.Value{})
-				if len(gqs) > 0 {
+				if arrayModel, ok := reflect.New(field.Type.Elem()).Interface().(ModelInterface); ok {
+					m.AddArrayField(jsonTag, map[string]any{"schema": GetSchema(arrayModel), "filter": dbTag})
+				} else if gqs := m.callMethod(reflect.New(field.Type.Elem()), "GetSchema", []reflect.Value{}); len(gqs) > 0 {
 					arraySchemaTemp := gqs[0].Interface()
 					arraySchema, _ := arraySchemaTemp.(map[string]any)
 					m.AddArrayField(jsonTag, map[string]any{"schema": arraySchema, "filter": dbTag})
@@ -225,6 +236,11 @@ func (m *Model) SetFields(p any) {
 				if field.Tag.Get("example") != "" {
 					fieldOpt["example"] = field.Tag.Get("example")
 				}
+				for _, k := range []string{"filter", "sort", "group"} {
+					if field.Tag.Get(k) != "" {
+						fieldOpt[k] = field.Tag.Get(k)
+					}
+				}
 				m.AddField(jsonTag, fieldOpt)
 			}
 		}
@@ -362,11 +378,16 @@ func contains(slice []string, value string) bool {
 //   - tableName :
 //   - tableAliasName
 //   - conditions : []map[string]any same as "Filters"
+//   - isRequired : if true, the left join is always joined, even if it is not referenced by the query
+//
+// the left join which is not referenced by the selected fields, filters, sorts, groups or another joined relation conditions is not joined,
+// set isRequired to true if the left join affects the result rows (ex: one to many relation that multiply the rows)
 //
 // example :
 //
 //	func (m *Model) GetRelations() map[string]map[string]any {
 //		m.AddRelation("left", "product_categories", "pc", []map[string]any{{"column1": "pc.id", "operator": "=", "column2": "p.category_id"}})
+//		m.Relations["pc"]["isRequired"] = true
 //		return m.Relations
 //	}
 func (m *Model) GetRelations() map[string]map[string]any {
@@ -425,7 +446,7 @@ func (m *Model) GetSorts() []map[string]any {
 
 // SetSchema sets the schema for the model.
 func (m *Model) SetSchema(model ModelInterface) map[string]any {
-	return map[string]any{
+	schema := map[string]any{
 		"tableName":       model.TableName(),
 		"tableSchema":     model.TableSchema(),
 		"tableAliasName":  model.TableAliasName(),
@@ -440,6 +461,19 @@ func (m *Model) SetSchema(model ModelInterface) map[string]any {
 		"sorts":           model.GetSorts(),
 		"isFlat":          model.IsFlat(),
 	}
+	if s, ok := model.(interface{ SearchStrategy() SearchStrategy }); ok {
+		schema["searchStrategy"] = s.SearchStrategy()
+	}
+	if p, ok := model.(interface{ QueryPolicy() QueryPolicy }); ok {
+		schema["queryPolicy"] = p.QueryPolicy()
+	}
+	if t, ok := model.(interface{ IsTenantGlobal() bool }); ok && t.IsTenantGlobal() {
+		schema["isTenantGlobal"] = true
+	}
+	if c, ok := model.(interface{ CacheTTL() time.Duration }); ok {
+		schema["cacheTTL"] = c.CacheTTL()
+	}
+	return schema
 }
 
 // GetSchema returns the model schema.
@@ -0,0 +1,94 @@
+package grest
+
+import (
+	"reflect"
+	"slices"
+	"sync"
+)
+
+// schemas is the schema registry keyed by the model type, see GetSchema
+var schemas sync.Map
+
+// GetSchema returns the schema of the model from the schema registry,
+// the schema is computed once per model type (using model.GetSchema) and each call returns a deep copy of it,
+// so the returned schema can be modified without affecting the other requests.
+//
+// the schema of the model that implements IsDynamicSchema() returning true is not registered (ex: the schema depends on the model values).
+func GetSchema(model ModelInterface) map[string]any {
+	if d, ok := model.(interface{ IsDynamicSchema() bool }); ok && d.IsDynamicSchema() {
+		return model.GetSchema()
+	}
+	t := reflect.TypeOf(model)
+	if schema, ok := schemas.Load(t); ok {
+		return CopySchema(schema.(map[string]any))
+	}
+	schema, _ := schemas.LoadOrStore(t, CopySchema(model.GetSchema()))
+	return CopySchema(schema.(map[string]any))
+}
+
+// DeleteSchema removes the schema of the model from the schema registry, so the next GetSchema computes it again
+func DeleteSchema(model ModelInterface) {
+	schemas.Delete(reflect.TypeOf(model))
+}
+
+// CopySchema returns a deep copy of the schema, including the nested schemas (array fields, relations & table schema)
+func CopySchema(schema map[string]any) map[string]any {
+	if schema == nil {
+		return nil
+	}
+	return copySchemaValue(schema).(map[string]any)
+}
+
+// copySchemaValue returns a deep copy of the maps and slices of the schema value, the other values are returned as is
+func copySchemaValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		if val == nil {
+			return val
+		}
+		res := make(map[string]any, len(val))
+		for k, v := range val {
+			res[k] = copySchemaValue(v)
+		}
+		return res
+	case map[string]map[string]any:
+		if val == nil {
+			return val
+		}
+		res := make(map[string]map[string]any, len(val))
+		for k, v := range val {
+			res[k], _ = copySchemaValue(v).(map[string]any)
+		}
+		return res
+	case map[string]string:
+		if val == nil {
+			return val
+		}
+		res := make(map[string]string, len(val))
+		for k, v := range val {
+			res[k] = v
+		}
+		return res
+	case []map[string]any:
+		if val == nil {
+			return val
+		}
+		res := make([]map[string]any, len(val))
+		for i, v := range val {
+			res[i], _ = copySchemaValue(v).(map[string]any)
+		}
+		return res
+	case []any:
+		if val == nil {
+			return val
+		}
+		res := make([]any, len(val))
+		for i, v := range val {
+			res[i] = copySchemaValue(v)
+		}
+		return res
+	case []string:
+		return slices.Clone(val)
+	}
+	return v
+}
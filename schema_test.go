@@ -0,0 +1,79 @@
+package grest
+
+import (
+	"encoding/json"
+	"net/url"
+	"sync"
+	"testing"
+)
+
+type dynamicSchemaModel struct {
+	Article
+	tableName string
+}
+
+func (m *dynamicSchemaModel) TableName() string {
+	return m.tableName
+}
+
+func (m *dynamicSchemaModel) GetSchema() map[string]any {
+	return m.SetSchema(m)
+}
+
+func (m *dynamicSchemaModel) IsDynamicSchema() bool {
+	return true
+}
+
+func TestGetSchema(t *testing.T) {
+	DeleteSchema(&Article{})
+	expected, _ := json.Marshal((&Article{}).GetSchema())
+
+	wg := sync.WaitGroup{}
+	results := make([][]byte, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _ = json.Marshal(GetSchema(&Article{}))
+		}(i)
+	}
+	wg.Wait()
+	for i, res := range results {
+		if string(res) != string(expected) {
+			t.Errorf("%v. Expected same schema as model.GetSchema, got:\n%s", i, res)
+		}
+	}
+
+	// the returned schema is a copy, including the nested maps and schemas
+	schema := GetSchema(&Article{})
+	schema["tableName"] = "changed"
+	fields, _ := schema["fields"].(map[string]map[string]any)
+	fields["title"]["db"] = "changed"
+	arrayFields, _ := schema["arrayFields"].(map[string]map[string]any)
+	arraySchema, _ := arrayFields["categories"]["schema"].(map[string]any)
+	arraySchema["tableName"] = "changed"
+	if res, _ := json.Marshal(GetSchema(&Article{})); string(res) != string(expected) {
+		t.Errorf("Expected the registered schema is not changed, got:\n%s", res)
+	}
+
+	// the query groups are not written to the schema groups
+	db, _, err := NewMockDB()
+	if err != nil {
+		t.Fatalf("Error occured : [%v]", err.Error())
+	}
+	schema = GetSchema(&Article{})
+	schema["groups"] = map[string]string{"author.id": "a.author_id"}
+	dq := &DBQuery{DB: db}
+	dq.ToSQL(schema, url.Values{QueryGroup: {"title"}})
+	if groups, _ := schema["groups"].(map[string]string); len(groups) != 1 {
+		t.Errorf("Expected the schema groups is not changed, got [%v]", groups)
+	}
+
+	// the dynamic schema is not registered
+	if tableName := GetSchema(&dynamicSchemaModel{tableName: "articles_2024"})["tableName"]; tableName != "articles_2024" {
+		t.Errorf("Expected the dynamic table name, got [%v]", tableName)
+	}
+	if tableName := GetSchema(&dynamicSchemaModel{tableName: "articles_2025"})["tableName"]; tableName != "articles_2025" {
+		t.Errorf("Expected the dynamic table name, got [%v]", tableName)
+	}
+}